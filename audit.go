@@ -0,0 +1,404 @@
+package yarninstall
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// auditSeverities lists the advisory severities in increasing order.
+var auditSeverities = []string{"low", "moderate", "high", "critical"}
+
+// osvAdvisory is the subset of the OSV schema
+// (https://ossf.github.io/osv-schema/) needed to audit npm packages.
+type osvAdvisory struct {
+	ID       string   `json:"id"`
+	Summary  string   `json:"summary"`
+	Aliases  []string `json:"aliases"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced   string `json:"introduced"`
+				Fixed        string `json:"fixed"`
+				LastAffected string `json:"last_affected"`
+			} `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// AuditFinding is a package version from the yarn.lock that is affected by an
+// advisory.
+type AuditFinding struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Package  string   `json:"package"`
+	Version  string   `json:"version"`
+	Severity string   `json:"severity"`
+	Scope    string   `json:"scope,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Fixed    []string `json:"fixed,omitempty"`
+}
+
+// AuditReport is the machine-readable result of an audit.
+type AuditReport struct {
+	Level      string         `json:"level"`
+	Advisories int            `json:"advisories"`
+	Packages   int            `json:"packages"`
+	Findings   []AuditFinding `json:"findings"`
+}
+
+// parseAuditLevel reads BP_YARN_AUDIT_LEVEL, the minimum severity of the
+// findings that fail the build. Findings below it are reported as warnings.
+func parseAuditLevel() (string, error) {
+	level := strings.ToLower(strings.TrimSpace(os.Getenv("BP_YARN_AUDIT_LEVEL")))
+	if level == "" {
+		return "high", nil
+	}
+
+	if severityRank(level) < 0 || level == "unknown" {
+		return "", fmt.Errorf("failed to parse BP_YARN_AUDIT_LEVEL: unknown level %q, supported levels are %s", level, strings.Join(auditSeverities, ", "))
+	}
+
+	return level, nil
+}
+
+// severityRank returns the position of a severity in auditSeverities. Unknown
+// severities are ranked as high so that they are not silently ignored.
+func severityRank(severity string) int {
+	if severity == "unknown" {
+		severity = "high"
+	}
+
+	for i, s := range auditSeverities {
+		if s == severity {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// auditYarnLock matches the exact versions of the packages in the yarn.lock
+// at the given project path against the OSV advisories at advisoriesPath,
+// which is either a single JSON document, a JSON array of documents or a zip
+// archive of JSON documents such as the npm/all.zip export of osv.dev.
+func auditYarnLock(advisoriesPath, projectPath, level string) (AuditReport, error) {
+	advisories, err := loadAdvisories(advisoriesPath)
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	scopes, err := dependencyScopes(projectPath)
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	byName := map[string][]osvAdvisory{}
+	for _, advisory := range advisories {
+		for _, affected := range advisory.Affected {
+			if affected.Package.Ecosystem == "npm" {
+				byName[affected.Package.Name] = append(byName[affected.Package.Name], advisory)
+			}
+		}
+	}
+
+	report := AuditReport{
+		Level:      level,
+		Advisories: len(advisories),
+		Packages:   len(entries),
+		Findings:   []AuditFinding{},
+	}
+
+	for _, entry := range entries {
+		version, err := semver.NewVersion(entry.Version)
+		if err != nil {
+			continue
+		}
+
+		seen := map[string]bool{}
+		for _, advisory := range byName[entry.Name] {
+			if seen[advisory.ID] {
+				continue
+			}
+
+			affected, fixed := advisory.affects(entry.Name, entry.Version, version)
+			if !affected {
+				continue
+			}
+			seen[advisory.ID] = true
+
+			finding := AuditFinding{
+				ID:       advisory.ID,
+				Aliases:  advisory.Aliases,
+				Package:  entry.Name,
+				Version:  entry.Version,
+				Severity: advisory.severity(),
+				Summary:  advisory.Summary,
+				Fixed:    fixed,
+			}
+
+			if production, ok := scopes[fmt.Sprintf("%s@%s", entry.Name, entry.Version)]; ok {
+				finding.Scope = "development"
+				if production {
+					finding.Scope = "production"
+				}
+			}
+
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) > severityRank(b.Severity)
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.ID < b.ID
+	})
+
+	return report, nil
+}
+
+// checkAudit logs the findings of the report, writes it to reportPath as JSON
+// and returns an error when any finding is at or above the report's level.
+func checkAudit(report AuditReport, reportPath string, logger scribe.Emitter) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(reportPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write audit report: %w", err)
+	}
+
+	logger.Subprocess("Checked %d packages against %d advisories", report.Packages, report.Advisories)
+
+	failing := 0
+	for _, finding := range report.Findings {
+		if severityRank(finding.Severity) >= severityRank(report.Level) {
+			failing++
+		}
+	}
+
+	if len(report.Findings) == 0 {
+		logger.Subprocess("No vulnerable packages found")
+		logger.Break()
+		return nil
+	}
+
+	logger.Subprocess("Found %d vulnerabilities:", len(report.Findings))
+	for _, finding := range report.Findings {
+		line := fmt.Sprintf("%s@%s: %s (%s)", finding.Package, finding.Version, finding.ID, finding.Severity)
+		if finding.Summary != "" {
+			line = fmt.Sprintf("%s %s", line, finding.Summary)
+		}
+		if len(finding.Fixed) > 0 {
+			line = fmt.Sprintf("%s, fixed in %s", line, strings.Join(finding.Fixed, ", "))
+		}
+		logger.Action("%s", line)
+	}
+	logger.Break()
+	logger.Subprocess("Wrote audit report to %s", reportPath)
+	logger.Break()
+
+	if failing > 0 {
+		return fmt.Errorf("failed audit: %d vulnerabilities at or above severity %q (BP_YARN_AUDIT_LEVEL)", failing, report.Level)
+	}
+
+	logger.Subprocess("All vulnerabilities are below severity %q (BP_YARN_AUDIT_LEVEL), continuing", report.Level)
+	logger.Break()
+
+	return nil
+}
+
+func loadAdvisories(path string) ([]osvAdvisory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory database: %w", err)
+	}
+
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return parseAdvisories(path, content)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory database: %w", err)
+	}
+
+	var advisories []osvAdvisory
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read advisory database: %w", err)
+		}
+
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read advisory database: %w", err)
+		}
+
+		parsed, err := parseAdvisories(file.Name, content)
+		if err != nil {
+			return nil, err
+		}
+		advisories = append(advisories, parsed...)
+	}
+
+	return advisories, nil
+}
+
+func parseAdvisories(name string, content []byte) ([]osvAdvisory, error) {
+	content = bytes.TrimSpace(content)
+
+	var advisories []osvAdvisory
+	var err error
+	if bytes.HasPrefix(content, []byte("[")) {
+		err = json.Unmarshal(content, &advisories)
+	} else {
+		var advisory osvAdvisory
+		err = json.Unmarshal(content, &advisory)
+		advisories = append(advisories, advisory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse advisory %s: %w", name, err)
+	}
+
+	return advisories, nil
+}
+
+func (a osvAdvisory) severity() string {
+	switch strings.ToLower(a.DatabaseSpecific.Severity) {
+	case "low":
+		return "low"
+	case "moderate", "medium":
+		return "moderate"
+	case "high":
+		return "high"
+	case "critical":
+		return "critical"
+	}
+
+	return "unknown"
+}
+
+// affects reports whether the given package version is affected by the
+// advisory and which versions fix it, following the range evaluation of the
+// OSV schema.
+func (a osvAdvisory) affects(name, raw string, version *semver.Version) (bool, []string) {
+	for _, affected := range a.Affected {
+		if affected.Package.Ecosystem != "npm" || affected.Package.Name != name {
+			continue
+		}
+
+		var fixed []string
+		for _, r := range affected.Ranges {
+			for _, event := range r.Events {
+				if event.Fixed != "" {
+					fixed = append(fixed, event.Fixed)
+				}
+			}
+		}
+
+		for _, v := range affected.Versions {
+			if v == raw {
+				return true, fixed
+			}
+		}
+
+		for _, r := range affected.Ranges {
+			if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+				continue
+			}
+
+			type event struct {
+				version *semver.Version
+				kind    string
+			}
+
+			var events []event
+			for _, e := range r.Events {
+				var kind, value string
+				switch {
+				case e.Introduced != "":
+					kind, value = "introduced", e.Introduced
+				case e.Fixed != "":
+					kind, value = "fixed", e.Fixed
+				case e.LastAffected != "":
+					kind, value = "last_affected", e.LastAffected
+				default:
+					continue
+				}
+
+				v, err := semver.NewVersion(value)
+				if err != nil {
+					continue
+				}
+				events = append(events, event{version: v, kind: kind})
+			}
+
+			sort.SliceStable(events, func(i, j int) bool {
+				return events[i].version.LessThan(events[j].version)
+			})
+
+			vulnerable := false
+			for _, e := range events {
+				switch e.kind {
+				case "introduced":
+					if !version.LessThan(e.version) {
+						vulnerable = true
+					}
+				case "fixed":
+					if !version.LessThan(e.version) {
+						vulnerable = false
+					}
+				case "last_affected":
+					if version.GreaterThan(e.version) {
+						vulnerable = false
+					}
+				}
+			}
+
+			if vulnerable {
+				return true, fixed
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,110 @@
+package yarninstall
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// binaryDownloads are the packages whose postinstall scripts download large
+// binaries that are only needed to run tests during the build, with the
+// variables that skip the download and the variable that sets where the
+// binaries are cached.
+var binaryDownloads = []struct {
+	name     string
+	skip     []string
+	cacheEnv string
+	cacheDir string
+}{
+	{name: "Cypress", skip: []string{"CYPRESS_INSTALL_BINARY=0"}, cacheEnv: "CYPRESS_CACHE_FOLDER", cacheDir: "cypress"},
+	{name: "Puppeteer", skip: []string{"PUPPETEER_SKIP_DOWNLOAD=true", "PUPPETEER_SKIP_CHROMIUM_DOWNLOAD=true"}, cacheEnv: "PUPPETEER_CACHE_DIR", cacheDir: "puppeteer"},
+	{name: "Playwright", skip: []string{"PLAYWRIGHT_SKIP_BROWSER_DOWNLOAD=1"}, cacheEnv: "PLAYWRIGHT_BROWSERS_PATH", cacheDir: "ms-playwright"},
+	{name: "Electron", skip: []string{"ELECTRON_SKIP_BINARY_DOWNLOAD=1"}, cacheEnv: "electron_config_cache", cacheDir: "electron"},
+}
+
+// binaryDownloadEnvironment returns the environment that applies the binary
+// download policy to yarn install. The launch install skips the downloads,
+// unless BP_YARN_SKIP_BINARY_DOWNLOADS is false, while the build install
+// keeps them in the cache layer. Variables that are already set in the
+// environment take precedence.
+func (ip YarnInstallProcess) binaryDownloadEnvironment(cacheLayerPath string, launch bool) ([]string, error) {
+	var env []string
+	if launch {
+		skip, err := parseBoolEnvDefault("BP_YARN_SKIP_BINARY_DOWNLOADS", true)
+		if err != nil {
+			return nil, err
+		}
+
+		if !skip {
+			return nil, nil
+		}
+
+		var names []string
+		for _, download := range binaryDownloads {
+			skipped := false
+			for _, variable := range download.skip {
+				name, _, _ := strings.Cut(variable, "=")
+				if _, ok := os.LookupEnv(name); ok {
+					continue
+				}
+				env = append(env, variable)
+				skipped = true
+			}
+
+			if skipped {
+				names = append(names, download.name)
+			}
+		}
+
+		if len(names) > 0 {
+			ip.logger.Subprocess("Skipping binary downloads of %s (set BP_YARN_SKIP_BINARY_DOWNLOADS=false to keep them)", strings.Join(names, ", "))
+		}
+
+		return env, nil
+	}
+
+	if cacheLayerPath == "" {
+		return nil, nil
+	}
+
+	for _, download := range binaryDownloads {
+		if _, ok := os.LookupEnv(download.cacheEnv); ok {
+			continue
+		}
+
+		dir := filepath.Join(cacheLayerPath, download.cacheDir)
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create binary download cache: %w", err)
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", download.cacheEnv, dir))
+	}
+
+	return env, nil
+}
+
+// binaryDownloadCaches returns the variables pointing at the binary download
+// caches in the cache layer that contain binaries, so that tools used later
+// in the build find them.
+func binaryDownloadCaches(cacheLayerPath string) (map[string]string, error) {
+	caches := map[string]string{}
+	for _, download := range binaryDownloads {
+		if _, ok := os.LookupEnv(download.cacheEnv); ok {
+			continue
+		}
+
+		dir := filepath.Join(cacheLayerPath, download.cacheDir)
+		ok, err := hasContents(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			caches[download.cacheEnv] = dir
+		}
+	}
+
+	return caches, nil
+}
@@ -0,0 +1,27 @@
+package yarninstall
+
+import (
+	"fmt"
+
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
+)
+
+// resolveOptionalBinding returns the binding of the given type, or nil when
+// no such binding has been provided. More than one binding of the type is an
+// error.
+func resolveOptionalBinding(bindingResolver BindingResolver, typ, platformDir string) (*servicebindings.Binding, error) {
+	bindings, err := bindingResolver.Resolve(typ, "", platformDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bindings) > 1 {
+		return nil, fmt.Errorf("failed: binding resolver found more than one binding of type '%s'", typ)
+	}
+
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+
+	return &bindings[0], nil
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/paketo-buildpacks/libnodejs"
 	"github.com/paketo-buildpacks/packit/v2"
@@ -24,7 +27,14 @@ type SymlinkManager interface {
 type InstallProcess interface {
 	ShouldRun(workingDir string, metadata map[string]interface{}) (run bool, sha string, err error)
 	SetupModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error)
-	Execute(workingDir, modulesLayerPath string, launch bool) error
+	Execute(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error)
+	Version(workingDir string) (string, error)
+}
+
+//go:generate faux --interface RebuildProcess --output fakes/rebuild_process.go
+type RebuildProcess interface {
+	NodeABI(workingDir string) (string, error)
+	Rebuild(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, packages []string) ([]string, error)
 }
 
 //go:generate faux --interface EntryResolver --output fakes/entry_resolver.go
@@ -47,6 +57,7 @@ func Build( entryResolver EntryResolver,
 	homeDir string,
 	symlinker SymlinkManager,
 	installProcess InstallProcess,
+	rebuildProcess RebuildProcess,
 	sbomGenerator SBOMGenerator,
 	clock chronos.Clock,
 	logger scribe.Emitter,
@@ -59,6 +70,11 @@ func Build( entryResolver EntryResolver,
 			return packit.BuildResult{}, err
 		}
 
+		err = checkAllowedRegistries(projectPath, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		globalNpmrcPath, err := configurationManager.DeterminePath("npmrc", context.Platform.Path, ".npmrc")
 		if err != nil {
 			return packit.BuildResult{}, err
@@ -90,8 +106,176 @@ func Build( entryResolver EntryResolver,
 			return packit.BuildResult{}, err
 		}
 
+		licensePolicy, err := loadLicensePolicy(configurationManager, context.WorkingDir, context.Platform.Path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		advisoriesPath, err := configurationManager.DeterminePath("osv-advisories", context.Platform.Path, "advisories")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var auditLayer *packit.Layer
+		if advisoriesPath != "" {
+			level, err := parseAuditLevel()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			logger.Process("Auditing yarn.lock against the advisory database")
+			report, err := auditYarnLock(advisoriesPath, projectPath, level)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err := context.Layers.Get("audit")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer, err = layer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			reportPath := filepath.Join(layer.Path, "report.json")
+			err = checkAudit(report, reportPath, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			layer.LaunchEnv.Default("YARN_AUDIT_REPORT", reportPath)
+			layer.Launch = true
+			auditLayer = &layer
+		}
+
+		thirdPartyNotices, err := parseBoolEnv("BP_YARN_THIRD_PARTY_NOTICES")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		generatorName, err := parseSBOMGenerator()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		generator := sbomGenerator
+		if generatorName == "lockfile" {
+			generator = NewLockfileSBOMGenerator(context.WorkingDir)
+		}
+
+		var scopes map[string]bool
+		var sbomCacheLayer packit.Layer
+		var formats []string
+		if !sbomDisabled {
+			formats, err = sbomFormats(context.BuildpackInfo.SBOMFormats)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			scopes, err = dependencyScopes(projectPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			sbomCacheLayer, err = context.Layers.Get("sbom-cache")
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		sbomCache := NewSBOMCache(sbomCacheLayer.Path)
+		sbomCached := false
+
+		// cachedSBOM returns the SBOM stored for the given layer by a previous
+		// build when it was generated from identical install inputs.
+		cachedSBOM := func(layer packit.Layer, sha string) (packit.SBOMFormatter, bool, error) {
+			formatter, ok, err := sbomCache.Lookup(layer.Name, sha, generatorName, formats)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if ok {
+				logger.Subprocess("Reusing cached SBOM for %s (cache_sha %s)", layer.Name, sha)
+				logger.Break()
+				sbomCached = true
+			}
+
+			return formatter, ok, nil
+		}
+
+		// The JavaScript catalogers of syft read the yarn.lock rather than
+		// node_modules, so the syft generator scans the project once and each
+		// layer's SBOM is filtered down to the packages installed in it. The
+		// lockfile generator reads the node_modules of the layer itself.
+		var projectSBOM *sbom.SBOM
+		generateSBOM := func(layer packit.Layer) (sbom.SBOM, error) {
+			if generatorName != "syft" {
+				return generator.Generate(layer.Path)
+			}
+
+			if projectSBOM == nil {
+				sbomContent, err := generator.Generate(context.WorkingDir)
+				if err != nil {
+					return sbom.SBOM{}, err
+				}
+				projectSBOM = &sbomContent
+			}
+
+			return *projectSBOM, nil
+		}
+
+		layerSBOM := func(layer packit.Layer, sha string) (packit.SBOMFormatter, error) {
+			formatter, ok, err := cachedSBOM(layer, sha)
+			if err != nil || ok {
+				return formatter, err
+			}
+
+			logger.GeneratingSBOM(layer.Path)
+			var sbomContent sbom.SBOM
+			duration, err := clock.Measure(func() error {
+				sbomContent, err = generateSBOM(layer)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			logger.Action("Completed in %s", duration.Round(time.Millisecond))
+			logger.Break()
+
+			logger.FormattingSBOM(formats...)
+			formatter, err = sbomContent.InFormats(formats...)
+			if err != nil {
+				return nil, err
+			}
+
+			if generatorName == "syft" {
+				formatter, err = newInstalledSBOMFormatter(formatter, layer.Path)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			formatter = newScopedSBOMFormatter(formatter, scopes)
+			if sha == "" {
+				return formatter, nil
+			}
+
+			sbomCached = true
+			return sbomCache.Store(layer.Name, sha, generatorName, formats, formatter)
+		}
+
 		var layers []packit.Layer
+		nativeCacheLayer, err := context.Layers.Get("native-build-cache")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var report BuildReport
+		var labels map[string]string
 		var currentModLayer string
+		var checkedBuildTools bool
 		if build {
 			layer, err := context.Layers.Get("build-modules")
 			if err != nil {
@@ -105,11 +289,47 @@ func Build( entryResolver EntryResolver,
 				return packit.BuildResult{}, err
 			}
 
+			previousSHA, _ := layer.Metadata["cache_sha"].(string)
+			layerReport := LayerReport{
+				Name:             layer.Name,
+				Status:           "reused",
+				CacheSHA:         previousSHA,
+				PreviousCacheSHA: previousSHA,
+				CacheInputs:      cacheInputs,
+			}
+
+			var nodeABI string
+			var stalePackages []string
+			if !run {
+				nodeABI, stalePackages, err = staleNativeAddons(rebuildProcess, projectPath, layer.Metadata)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				var skippedPackages []string
+				stalePackages, skippedPackages, err = allowedRebuildPackages(stalePackages)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if len(skippedPackages) > 0 {
+					logger.Subprocess("Not rebuilding native addons of packages that are not in BP_YARN_ALLOWED_SCRIPTS: %s", strings.Join(skippedPackages, ", "))
+				}
+			}
+
 			if run {
 				logger.Subprocess("Selected default build process: 'yarn install'")
 				logger.Break()
 				logger.Process("Executing build environment install process")
 
+				if !checkedBuildTools {
+					err = checkBuildTools(projectPath, logger)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					checkedBuildTools = true
+				}
+
 				layer, err = layer.Reset()
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -120,8 +340,10 @@ func Build( entryResolver EntryResolver,
 					return packit.BuildResult{}, err
 				}
 
+				var commands []string
 				duration, err := clock.Measure(func() error {
-					return installProcess.Execute(projectPath, layer.Path, false)
+					commands, err = installProcess.Execute(projectPath, layer.Path, nativeCacheLayer.Path, context.Platform.Path, false)
+					return err
 				})
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -130,10 +352,30 @@ func Build( entryResolver EntryResolver,
 				logger.Action("Completed in %s", duration.Round(time.Millisecond))
 				logger.Break()
 
+				layerReport.Status = "rebuilt"
+				layerReport.CacheSHA = sha
+				layerReport.InstallDuration = duration.String()
+				layerReport.Commands = commands
+
 				layer.Metadata = map[string]interface{}{
 					"cache_sha": sha,
 				}
 
+				addons, err := scanNativeAddons(layer.Path, defaultLibraryPaths(), "")
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if len(addons) > 0 {
+					logNativeAddons(addons, "build environment", logger)
+					layer.Metadata["native_addons"] = addons
+
+					layer.Metadata["node_abi"], err = rebuildProcess.NodeABI(projectPath)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+
 				err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -142,6 +384,16 @@ func Build( entryResolver EntryResolver,
 				path := filepath.Join(layer.Path, "node_modules", ".bin")
 				layer.BuildEnv.Append("PATH", path, string(os.PathListSeparator))
 				layer.BuildEnv.Override("NODE_ENV", "development")
+				layer.BuildEnv.Override("YARN_INSTALL_BUILD_REPORT", filepath.Join(layer.Path, "build-report.toml"))
+
+				downloadCaches, err := binaryDownloadCaches(nativeCacheLayer.Path)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				for name, path := range downloadCaches {
+					layer.BuildEnv.Default(name, path)
+				}
 
 				logger.EnvironmentVariables(layer)
 
@@ -150,20 +402,51 @@ func Build( entryResolver EntryResolver,
 					logger.Break()
 
 				} else {
-					logger.GeneratingSBOM(layer.Path)
-					var sbomContent sbom.SBOM
-					duration, err = clock.Measure(func() error {
-						sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
-						return err
-					})
+					layer.SBOM, err = layerSBOM(layer, sha)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
-					logger.Action("Completed in %s", duration.Round(time.Millisecond))
-					logger.Break()
+				}
+			} else if len(stalePackages) > 0 {
+				logger.Process("Rebuilding native addons for NODE_MODULE_VERSION %s (was %s)", nodeABI, layer.Metadata["node_abi"])
+				logger.Subprocess("Keeping cached layer %s and rebuilding:", layer.Path)
+				for _, p := range stalePackages {
+					logger.Action("%s", p)
+				}
+				logger.Break()
 
-					logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-					layer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+				var commands []string
+				duration, err := clock.Measure(func() error {
+					commands, err = rebuildProcess.Rebuild(projectPath, layer.Path, nativeCacheLayer.Path, context.Platform.Path, stalePackages)
+					return err
+				})
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				logger.Action("Completed in %s", duration.Round(time.Millisecond))
+				logger.Break()
+
+				layerReport.Status = "rebuilt-native"
+				layerReport.InstallDuration = duration.String()
+				layerReport.Commands = commands
+
+				addons, err := scanNativeAddons(layer.Path, defaultLibraryPaths(), "")
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				layer.Metadata["native_addons"] = addons
+				layer.Metadata["node_abi"] = nodeABI
+
+				err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				layer.BuildEnv.Override("YARN_INSTALL_BUILD_REPORT", filepath.Join(layer.Path, "build-report.toml"))
+
+				if !sbomDisabled {
+					layer.SBOM, _, err = cachedSBOM(layer, previousSHA)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
@@ -175,12 +458,23 @@ func Build( entryResolver EntryResolver,
 				if err != nil {
 					return packit.BuildResult{}, err
 				}
+
+				layer.BuildEnv.Override("YARN_INSTALL_BUILD_REPORT", filepath.Join(layer.Path, "build-report.toml"))
+
+				if !sbomDisabled {
+					sha, _ := layer.Metadata["cache_sha"].(string)
+					layer.SBOM, _, err = cachedSBOM(layer, sha)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
 			}
 
 			layer.Build = true
 			layer.Cache = true
 
 			layers = append(layers, layer)
+			report.Layers = append(report.Layers, layerReport)
 		}
 
 		if launch {
@@ -196,11 +490,47 @@ func Build( entryResolver EntryResolver,
 				return packit.BuildResult{}, err
 			}
 
+			previousSHA, _ := layer.Metadata["cache_sha"].(string)
+			layerReport := LayerReport{
+				Name:             layer.Name,
+				Status:           "reused",
+				CacheSHA:         previousSHA,
+				PreviousCacheSHA: previousSHA,
+				CacheInputs:      cacheInputs,
+			}
+
+			// The launch layer is not cached, so its contents are not available
+			// to rebuild its native addons in place and it is reinstalled instead.
+			if !run {
+				nodeABI, stalePackages, err := staleNativeAddons(rebuildProcess, projectPath, layer.Metadata)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if len(stalePackages) > 0 {
+					logger.Subprocess("Native addons were built for NODE_MODULE_VERSION %s, reinstalling for %s", layer.Metadata["node_abi"], nodeABI)
+					run, sha = true, previousSHA
+				} else if licensePolicy != nil && layer.Metadata["license_policy"] != licensePolicy.digest() {
+					// The packages of a reused launch layer are not available to
+					// check, so a changed policy is evaluated against a reinstall.
+					logger.Subprocess("License policy changed since the layer was installed, reinstalling to check it")
+					run, sha = true, previousSHA
+				}
+			}
+
 			if run {
 				logger.Subprocess("Selected default build process: 'yarn install'")
 				logger.Break()
 				logger.Process("Executing launch environment install process")
 
+				if !checkedBuildTools {
+					err = checkBuildTools(projectPath, logger)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					checkedBuildTools = true
+				}
+
 				layer, err = layer.Reset()
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -211,8 +541,10 @@ func Build( entryResolver EntryResolver,
 					return packit.BuildResult{}, err
 				}
 
+				var commands []string
 				duration, err := clock.Measure(func() error {
-					return installProcess.Execute(projectPath, layer.Path, true)
+					commands, err = installProcess.Execute(projectPath, layer.Path, nativeCacheLayer.Path, context.Platform.Path, true)
+					return err
 				})
 				if err != nil {
 					return packit.BuildResult{}, err
@@ -221,6 +553,11 @@ func Build( entryResolver EntryResolver,
 				logger.Action("Completed in %s", duration.Round(time.Millisecond))
 				logger.Break()
 
+				layerReport.Status = "rebuilt"
+				layerReport.CacheSHA = sha
+				layerReport.InstallDuration = duration.String()
+				layerReport.Commands = commands
+
 				if !build {
 					err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 					if err != nil {
@@ -228,13 +565,65 @@ func Build( entryResolver EntryResolver,
 					}
 				}
 
+				yarnVersion, err := installProcess.Version(projectPath)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				labels, err = launchLabels(projectPath, layer.Path, yarnVersion)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
 				layer.Metadata = map[string]interface{}{
 					"cache_sha": sha,
+					"labels":    labels,
+				}
+
+				addons, err := scanNativeAddons(layer.Path, defaultLibraryPaths(), context.Stack)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if len(addons) > 0 {
+					environment := "build environment"
+					if isTinyStack(context.Stack) {
+						environment = "tiny run image"
+					}
+					logNativeAddons(addons, environment, logger)
+					layer.Metadata["native_addons"] = addons
+
+					layer.Metadata["node_abi"], err = rebuildProcess.NodeABI(projectPath)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
 				}
 
 				path := filepath.Join(layer.Path, "node_modules", ".bin")
 				layer.LaunchEnv.Append("PATH", path, string(os.PathListSeparator))
 				layer.LaunchEnv.Default("NODE_PROJECT_PATH", projectPath)
+				layer.LaunchEnv.Override("YARN_INSTALL_BUILD_REPORT", filepath.Join(layer.Path, "build-report.toml"))
+
+				if licensePolicy != nil {
+					err = checkLicensePolicy(*licensePolicy, filepath.Join(layer.Path, "node_modules"), logger)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					layer.Metadata["license_policy"] = licensePolicy.digest()
+				}
+
+				if thirdPartyNotices {
+					logger.Process("Writing third-party notices")
+					noticesPath := filepath.Join(layer.Path, "THIRD_PARTY_NOTICES")
+					count, err := writeThirdPartyNotices(filepath.Join(layer.Path, "node_modules"), noticesPath)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+					logger.Subprocess("Listed %d packages in %s", count, noticesPath)
+					logger.Break()
+
+					layer.LaunchEnv.Default("THIRD_PARTY_NOTICES_PATH", noticesPath)
+				}
 
 				logger.EnvironmentVariables(layer)
 
@@ -243,20 +632,7 @@ func Build( entryResolver EntryResolver,
 					logger.Break()
 
 				} else {
-					logger.GeneratingSBOM(layer.Path)
-					var sbomContent sbom.SBOM
-					duration, err = clock.Measure(func() error {
-						sbomContent, err = sbomGenerator.Generate(context.WorkingDir)
-						return err
-					})
-					if err != nil {
-						return packit.BuildResult{}, err
-					}
-					logger.Action("Completed in %s", duration.Round(time.Millisecond))
-					logger.Break()
-
-					logger.FormattingSBOM(context.BuildpackInfo.SBOMFormats...)
-					layer.SBOM, err = sbomContent.InFormats(context.BuildpackInfo.SBOMFormats...)
+					layer.SBOM, err = layerSBOM(layer, sha)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
@@ -266,18 +642,77 @@ func Build( entryResolver EntryResolver,
 
 			} else {
 				logger.Process("Reusing cached layer %s", layer.Path)
+				labels = labelsFromMetadata(layer.Metadata)
+
 				if !build {
 					err = ensureNodeModulesSymlink(projectPath, layer.Path, tmpDir)
 					if err != nil {
 						return packit.BuildResult{}, err
 					}
 				}
+
+				if !sbomDisabled {
+					sha, _ := layer.Metadata["cache_sha"].(string)
+					layer.SBOM, _, err = cachedSBOM(layer, sha)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
 			}
 
 			layer.Launch = true
 
 			layers = append(layers, layer)
+			report.Layers = append(report.Layers, layerReport)
+
+		}
+
+		// The report describes every modules layer, so it is only written once
+		// all of them have been resolved. A reused launch layer that is not
+		// cached has no contents in this build and is left untouched, as
+		// adding files to it would replace it in the image.
+		for i, layerReport := range report.Layers {
+			if layerReport.Status == "reused" && !layers[i].Cache {
+				continue
+			}
+
+			summary, err := summarizeModules(filepath.Join(layers[i].Path, "node_modules"))
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			report.Layers[i].Packages = summary.Packages
+			report.Layers[i].Size = summary.Size
+		}
+
+		for i, layerReport := range report.Layers {
+			if layerReport.Status == "reused" && !layers[i].Cache {
+				continue
+			}
+
+			err := report.write(filepath.Join(layers[i].Path, "build-report.toml"))
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		if auditLayer != nil {
+			layers = append(layers, *auditLayer)
+		}
+
+		// The cache is only kept once a native build has stored something in it.
+		nativeCached, err := hasNativeBuildDownloads(nativeCacheLayer.Path)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
+		if nativeCached {
+			nativeCacheLayer.Cache = true
+			layers = append(layers, nativeCacheLayer)
+		}
+
+		if sbomCached {
+			sbomCacheLayer.Cache = true
+			layers = append(layers, sbomCacheLayer)
 		}
 
 		err = symlinker.Unlink(filepath.Join(homeDir, ".npmrc"))
@@ -290,9 +725,15 @@ func Build( entryResolver EntryResolver,
 			return packit.BuildResult{}, err
 		}
 
-		return packit.BuildResult{
+		result := packit.BuildResult{
 			Layers: layers,
-		}, nil
+		}
+
+		if len(labels) > 0 {
+			result.Launch.Labels = labels
+		}
+
+		return result, nil
 	}
 }
 
@@ -307,6 +748,83 @@ func checkSbomDisabled() (bool, error) {
 	return false, nil
 }
 
+// parseBoolEnv returns the boolean value of the given environment variable,
+// defaulting to false when it is unset.
+func parseBoolEnv(name string) (bool, error) {
+	return parseBoolEnvDefault(name, false)
+}
+
+// parseBoolEnvDefault returns the boolean value of the given environment
+// variable, or the given default when it is unset.
+func parseBoolEnvDefault(name string, defaultValue bool) (bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultValue, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s value %s: %w", name, value, err)
+	}
+
+	return enabled, nil
+}
+
+// parseSBOMGenerator returns the SBOM generator selected by
+// BP_YARN_SBOM_GENERATOR: "syft" (the default) scans the layers with syft,
+// while "lockfile" builds the SBOM from yarn.lock and the installed
+// package.json files.
+func parseSBOMGenerator() (string, error) {
+	switch generator := os.Getenv("BP_YARN_SBOM_GENERATOR"); generator {
+	case "", "syft":
+		return "syft", nil
+	case "lockfile":
+		return generator, nil
+	default:
+		return "", fmt.Errorf("failed to parse BP_YARN_SBOM_GENERATOR: unsupported value %q, must be one of 'syft' or 'lockfile'", generator)
+	}
+}
+
+// sbomFormats returns the subset of the given supported SBOM media types that
+// is selected by BP_SBOM_FORMATS, a comma-separated list of media types or
+// their short names (cyclonedx, spdx, syft). All supported formats are
+// returned when it is unset.
+func sbomFormats(supported []string) ([]string, error) {
+	value, ok := os.LookupEnv("BP_SBOM_FORMATS")
+	if !ok || strings.TrimSpace(value) == "" {
+		return supported, nil
+	}
+
+	shortNames := map[string]string{
+		"cyclonedx": sbom.CycloneDXFormat,
+		"cdx":       sbom.CycloneDXFormat,
+		"spdx":      sbom.SPDXFormat,
+		"syft":      sbom.SyftFormat,
+	}
+
+	selected := map[string]bool{}
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		mediaType := field
+		if m, ok := shortNames[strings.ToLower(field)]; ok {
+			mediaType = m
+		}
+
+		if !slices.Contains(supported, mediaType) {
+			return nil, fmt.Errorf("failed to parse BP_SBOM_FORMATS: unknown SBOM format %q, supported formats are %s", field, strings.Join(supported, ", "))
+		}
+		selected[mediaType] = true
+	}
+
+	var formats []string
+	for _, mediaType := range supported {
+		if selected[mediaType] {
+			formats = append(formats, mediaType)
+		}
+	}
+
+	return formats, nil
+}
+
 func ensureNodeModulesSymlink(projectDir, targetLayer, tmpDir string) error {
 	projectDirNodeModules := filepath.Join(projectDir, "node_modules")
 	layerNodeModules := filepath.Join(targetLayer, "node_modules")
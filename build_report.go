@@ -0,0 +1,52 @@
+package yarninstall
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// cacheInputs lists what the cache_sha of a modules layer is computed from,
+// see YarnInstallProcess.ShouldRun.
+var cacheInputs = []string{"yarn.lock", "package.json", "yarn config list", "NODE_ENV", "BP_YARN_REGISTRY_MIRROR", "BP_YARN_IGNORE_SCRIPTS", "BP_YARN_ALLOWED_SCRIPTS", "BP_YARN_SKIP_BINARY_DOWNLOADS"}
+
+// BuildReport describes what happened to each modules layer during a build.
+// It is written as build-report.toml into the modules layers so that
+// downstream buildpacks and CI tooling do not need to parse the build log.
+type BuildReport struct {
+	Layers []LayerReport `toml:"layers"`
+}
+
+type LayerReport struct {
+	Name             string   `toml:"name"`
+	Status           string   `toml:"status"`
+	CacheSHA         string   `toml:"cache_sha,omitempty"`
+	PreviousCacheSHA string   `toml:"previous_cache_sha,omitempty"`
+	CacheInputs      []string `toml:"cache_inputs"`
+	InstallDuration  string   `toml:"install_duration,omitempty"`
+	Commands         []string `toml:"commands,omitempty"`
+	Packages         int      `toml:"packages"`
+	Size             int64    `toml:"size"`
+}
+
+func (r BuildReport) write(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+	defer file.Close()
+
+	err = toml.NewEncoder(file).Encode(r)
+	if err != nil {
+		return fmt.Errorf("failed to write build report: %w", err)
+	}
+
+	return nil
+}
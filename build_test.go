@@ -1,7 +1,11 @@
 package yarninstall_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +15,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/BurntSushi/toml"
+	"github.com/anchore/syft/syft/pkg"
+	syftsbom "github.com/anchore/syft/syft/sbom"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
 	"github.com/paketo-buildpacks/packit/v2/sbom"
@@ -49,6 +56,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		buffer               *bytes.Buffer
 		entryResolver        *fakes.EntryResolver
 		installProcess       *fakes.InstallProcess
+		rebuildProcess       *fakes.RebuildProcess
 		linkCalls            []linkCallParams
 		sbomGenerator        *fakes.SBOMGenerator
 		symlinker            *fakes.SymlinkManager
@@ -80,6 +88,9 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			return true, "some-awesome-shasum", nil
 		}
 
+		rebuildProcess = &fakes.RebuildProcess{}
+		rebuildProcess.NodeABICall.Returns.String = "115"
+
 		entryResolver = &fakes.EntryResolver{}
 
 		buffer = bytes.NewBuffer(nil)
@@ -118,6 +129,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			homeDir,
 			symlinker,
 			installProcess,
+			rebuildProcess,
 			sbomGenerator,
 			chronos.DefaultClock,
 			scribe.NewEmitter(buffer),
@@ -163,15 +175,16 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(1))
+			Expect(len(result.Layers)).To(Equal(2))
 
 			layer := result.Layers[0]
 			Expect(layer.Name).To(Equal("build-modules"))
 			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "build-modules")))
 			Expect(layer.BuildEnv).To(Equal(packit.Environment{
-				"PATH.append":       filepath.Join(layersDir, "build-modules", "node_modules", ".bin"),
-				"PATH.delim":        ":",
-				"NODE_ENV.override": "development",
+				"PATH.append":                        filepath.Join(layersDir, "build-modules", "node_modules", ".bin"),
+				"PATH.delim":                         ":",
+				"NODE_ENV.override":                  "development",
+				"YARN_INSTALL_BUILD_REPORT.override": filepath.Join(layersDir, "build-modules", "build-report.toml"),
 			}))
 			Expect(layer.Build).To(BeTrue())
 			Expect(layer.Cache).To(BeTrue())
@@ -280,7 +293,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(len(layer.ExecD)).To(Equal(0))
 
-			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(2))
+			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(4))
 
 			Expect(determinePathCalls[0].Typ).To(Equal("npmrc"))
 			Expect(determinePathCalls[0].PlatformDir).To(Equal("some-platform-path"))
@@ -290,6 +303,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(determinePathCalls[1].PlatformDir).To(Equal("some-platform-path"))
 			Expect(determinePathCalls[1].Entry).To(Equal(".yarnrc"))
 
+			Expect(determinePathCalls[2].Typ).To(Equal("license-policy"))
+			Expect(determinePathCalls[2].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[2].Entry).To(Equal("policy.toml"))
+
+			Expect(determinePathCalls[3].Typ).To(Equal("osv-advisories"))
+			Expect(determinePathCalls[3].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[3].Entry).To(Equal("advisories"))
+
 			Expect(symlinker.LinkCall.CallCount).To(BeZero())
 
 			Expect(installProcess.ShouldRunCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
@@ -300,6 +321,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(installProcess.ExecuteCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
 			Expect(installProcess.ExecuteCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "build-modules")))
+			Expect(installProcess.ExecuteCall.Receives.PlatformDir).To(Equal("some-platform-path"))
 			Expect(installProcess.ExecuteCall.Receives.Launch).To(BeFalse())
 
 			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
@@ -338,22 +360,30 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(len(result.Layers)).To(Equal(1))
+			Expect(len(result.Layers)).To(Equal(2))
 			layer := result.Layers[0]
 			Expect(layer.Name).To(Equal("launch-modules"))
 			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "launch-modules")))
 			Expect(layer.LaunchEnv).To(Equal(packit.Environment{
-				"NODE_PROJECT_PATH.default": filepath.Join(workingDir, "some-project-dir"),
-				"PATH.append":               filepath.Join(layersDir, "launch-modules", "node_modules", ".bin"),
-				"PATH.delim":                ":",
+				"NODE_PROJECT_PATH.default":          filepath.Join(workingDir, "some-project-dir"),
+				"PATH.append":                        filepath.Join(layersDir, "launch-modules", "node_modules", ".bin"),
+				"PATH.delim":                         ":",
+				"YARN_INSTALL_BUILD_REPORT.override": filepath.Join(layersDir, "launch-modules", "build-report.toml"),
 			}))
 			Expect(layer.Launch).To(BeTrue())
 			Expect(layer.Build).To(BeFalse())
 			Expect(layer.Metadata).To(Equal(
 				map[string]interface{}{
 					"cache_sha": "some-awesome-shasum",
+					"labels": map[string]string{
+						"io.paketo.yarn-install.production-packages": "0",
+						"io.paketo.yarn-install.node-modules-size":   "0",
+						"io.paketo.yarn-install.native-addons":       "false",
+					},
 				}))
 
+			Expect(result.Launch.Labels).To(Equal(layer.Metadata["labels"]))
+
 			Expect(layer.SBOM.Formats()).To(HaveLen(3))
 
 			cdx := layer.SBOM.Formats()[0]
@@ -453,7 +483,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 				}
 			}`))
 
-			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(2))
+			Expect(configurationManager.DeterminePathCall.CallCount).To(Equal(4))
 
 			Expect(determinePathCalls[0].Typ).To(Equal("npmrc"))
 			Expect(determinePathCalls[0].PlatformDir).To(Equal("some-platform-path"))
@@ -463,6 +493,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(determinePathCalls[1].PlatformDir).To(Equal("some-platform-path"))
 			Expect(determinePathCalls[1].Entry).To(Equal(".yarnrc"))
 
+			Expect(determinePathCalls[2].Typ).To(Equal("license-policy"))
+			Expect(determinePathCalls[2].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[2].Entry).To(Equal("policy.toml"))
+
+			Expect(determinePathCalls[3].Typ).To(Equal("osv-advisories"))
+			Expect(determinePathCalls[3].PlatformDir).To(Equal("some-platform-path"))
+			Expect(determinePathCalls[3].Entry).To(Equal("advisories"))
+
 			Expect(symlinker.LinkCall.CallCount).To(BeZero())
 
 			Expect(installProcess.ShouldRunCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
@@ -473,6 +511,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			Expect(installProcess.ExecuteCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
 			Expect(installProcess.ExecuteCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "launch-modules")))
+			Expect(installProcess.ExecuteCall.Receives.PlatformDir).To(Equal("some-platform-path"))
 			Expect(installProcess.ExecuteCall.Receives.Launch).To(BeTrue())
 
 			Expect(sbomGenerator.GenerateCall.Receives.Dir).To(Equal(workingDir))
@@ -571,7 +610,13 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 
 			launchLayer := result.Layers[1]
 			Expect(launchLayer.ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "setup-symlinks")}))
-			Expect(len(result.Layers)).To(Equal(2))
+			Expect(len(result.Layers)).To(Equal(3))
+
+			sbomCacheLayer := result.Layers[2]
+			Expect(sbomCacheLayer.Name).To(Equal("sbom-cache"))
+			Expect(sbomCacheLayer.Cache).To(BeTrue())
+			Expect(sbomCacheLayer.Build).To(BeFalse())
+			Expect(sbomCacheLayer.Launch).To(BeFalse())
 
 			Expect(installProcess.SetupModulesCall.CallCount).To(Equal(2))
 
@@ -593,6 +638,146 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the project has development dependencies", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "package.json"), []byte(`{
+				"dependencies": { "left-pad": "^1.3.0" },
+				"devDependencies": { "right-pad": "^1.0.0" }
+			}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+left-pad@^1.3.0:
+  version "1.3.0"
+  dependencies:
+    pad-core "^2.0.0"
+
+pad-core@^2.0.0:
+  version "2.0.0"
+
+right-pad@^1.0.0:
+  version "1.0.1"
+`), os.ModePerm)).To(Succeed())
+
+			var packages []pkg.Package
+			for name, version := range map[string]string{"left-pad": "1.3.0", "pad-core": "2.0.0", "right-pad": "1.0.1", "unrelated": "0.0.1"} {
+				p := pkg.Package{Name: name, Version: version, Type: pkg.NpmPkg}
+				p.SetID()
+				packages = append(packages, p)
+			}
+
+			sbomGenerator.GenerateCall.Returns.SBOM = sbom.NewSBOM(syftsbom.SBOM{
+				Artifacts: syftsbom.Artifacts{
+					Packages: pkg.NewCollection(packages...),
+				},
+			})
+		})
+
+		it("marks the CycloneDX components with their dev/prod scope", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(result.Layers[0].SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+
+			var bom struct {
+				Components []struct {
+					Name  string `json:"name"`
+					Scope string `json:"scope"`
+				} `json:"components"`
+			}
+			Expect(json.Unmarshal(content, &bom)).To(Succeed())
+
+			scopes := map[string]string{}
+			for _, component := range bom.Components {
+				scopes[component.Name] = component.Scope
+			}
+
+			Expect(scopes).To(Equal(map[string]string{
+				"left-pad":  "required",
+				"pad-core":  "required",
+				"right-pad": "excluded",
+				"unrelated": "",
+			}))
+		})
+	})
+
+	context("when the SBOM is generated by syft", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "package.json"), []byte(`{
+				"dependencies": { "leftpad": "~0.0.1" },
+				"devDependencies": { "rightpad": "^1.0.0" }
+			}`), os.ModePerm)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+
+leftpad@~0.0.1:
+  version "0.0.1"
+  resolved "https://registry.yarnpkg.com/leftpad/-/leftpad-0.0.1.tgz#86b1a4de4face180ac545a83f1503523d8fed115"
+  integrity sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=
+
+rightpad@^1.0.0:
+  version "1.0.0"
+  resolved "https://registry.yarnpkg.com/rightpad/-/rightpad-1.0.0.tgz#1a9a2b2e8b1b3d8e8c7b5a8b3b0c9e1b0d3c4f2a"
+  integrity sha1-GporLoseXY6Me1qLOwyeGw08Tyo=
+`), os.ModePerm)).To(Succeed())
+
+			installProcess.ExecuteCall.Stub = func(_, modulesLayerPath, _, _ string, _ bool) ([]string, error) {
+				packageDir := filepath.Join(modulesLayerPath, "node_modules", "leftpad")
+				Expect(os.MkdirAll(packageDir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(packageDir, "package.json"), []byte(`{"name": "leftpad", "version": "0.0.1"}`), 0600)).To(Succeed())
+				return nil, nil
+			}
+
+			sbomGenerator.GenerateCall.Stub = sbom.Generate
+		})
+
+		it("scans the yarn.lock and only lists the packages installed in the layer", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("launch-modules"))
+			Expect(layer.SBOM.Formats()).To(HaveLen(3))
+
+			for _, format := range layer.SBOM.Formats() {
+				content, err := io.ReadAll(format.Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"name": "leftpad"`), format.Extension)
+				Expect(string(content)).NotTo(ContainSubstring("rightpad"), format.Extension)
+			}
+		})
+	})
+
 	context("when re-using previous modules layer", func() {
 		it.Before(func() {
 			installProcess.ShouldRunCall.Stub = nil
@@ -702,153 +887,1545 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("failure cases", func() {
+	context("when BP_YARN_THIRD_PARTY_NOTICES is set", func() {
+		var buildContext packit.BuildContext
 
-		context("when the project path parser provided fails", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			t.Setenv("BP_YARN_THIRD_PARTY_NOTICES", "true")
+
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				leftPad := filepath.Join(modulesLayerPath, "node_modules", "left-pad")
+				Expect(os.MkdirAll(leftPad, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(leftPad, "package.json"), []byte(`{"name": "left-pad", "version": "1.3.0", "license": "WTFPL"}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(leftPad, "LICENSE.md"), []byte("some-left-pad-license-text\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(leftPad, "NOTICE"), []byte("some-left-pad-notice\n"), 0600)).To(Succeed())
+
+				unlicensed := filepath.Join(modulesLayerPath, "node_modules", "@some-scope", "unlicensed")
+				Expect(os.MkdirAll(unlicensed, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(unlicensed, "package.json"), []byte(`{"name": "@some-scope/unlicensed", "version": "0.1.0"}`), 0600)).To(Succeed())
+
+				return nil, nil
+			}
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("writes a notices file into the launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			layer := result.Layers[0]
+			noticesPath := filepath.Join(layersDir, "launch-modules", "THIRD_PARTY_NOTICES")
+			Expect(layer.LaunchEnv).To(HaveKeyWithValue("THIRD_PARTY_NOTICES_PATH.default", noticesPath))
+
+			content, err := os.ReadFile(noticesPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(strings.Join([]string{
+				"@some-scope/unlicensed@0.1.0",
+				"License: UNKNOWN",
+			}, "\n")))
+			Expect(string(content)).To(ContainSubstring(strings.Join([]string{
+				"left-pad@1.3.0",
+				"License: WTFPL",
+				"",
+				"--- LICENSE.md ---",
+				"some-left-pad-license-text",
+				"",
+				"--- NOTICE ---",
+				"some-left-pad-notice",
+			}, "\n")))
+			Expect(strings.Index(string(content), "@some-scope/unlicensed")).To(BeNumerically("<", strings.Index(string(content), "left-pad@1.3.0")))
+
+			Expect(buffer.String()).To(ContainSubstring("Writing third-party notices"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Listed 2 packages in %s", noticesPath)))
+		})
+
+		context("when BP_YARN_THIRD_PARTY_NOTICES is set incorrectly", func() {
 			it.Before(func() {
-				t.Setenv("BP_NODE_PROJECT_PATH", "does_not_exist")
+				t.Setenv("BP_YARN_THIRD_PARTY_NOTICES", "not-a-bool")
 			})
 
 			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					Layers:     packit.Layers{Path: layersDir},
-					CNBPath:    cnbDir,
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
-					},
-				})
-				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("could not find project path \"%s/does_not_exist\": stat %s/does_not_exist: no such file or directory", workingDir, workingDir))))
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_YARN_THIRD_PARTY_NOTICES value not-a-bool")))
 			})
 		})
+	})
 
-		context("when determining the path for the npmrc fails", func() {
-			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "npmrc" {
-						return "", errors.New("failed to determine path for npmrc")
-					}
-					return "", nil
+	context("when a license policy is configured", func() {
+		var (
+			buildContext packit.BuildContext
+			policyPath   string
+		)
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			policyPath = filepath.Join(t.TempDir(), "policy.toml")
+			Expect(os.WriteFile(policyPath, []byte(`
+allow = ["MIT", "Apache-2.0", "BSD-*"]
+deny = ["AGPL-*"]
+`), 0600)).To(Succeed())
+
+			packages := map[string]string{
+				"mit-package":        `"license": "MIT"`,
+				"bsd-package":        `"license": "BSD-3-Clause"`,
+				"dual-package":       `"license": "(AGPL-3.0-only OR Apache-2.0)"`,
+				"combined-package":   `"license": "MIT AND AGPL-3.0-only"`,
+				"gpl-package":        `"licenses": [{"type": "GPL-2.0"}]`,
+				"unlicensed-package": `"private": true`,
+			}
+
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				for name, license := range packages {
+					dir := filepath.Join(modulesLayerPath, "node_modules", name)
+					Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(dir, "package.json"), []byte(fmt.Sprintf(`{"name": %q, "version": "1.0.0", %s}`, name, license)), 0600)).To(Succeed())
 				}
-			})
+				return nil, nil
+			}
 
-			it("errors", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Layers:     packit.Layers{Path: layersDir},
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
-					},
-				})
-				Expect(err).To(MatchError("failed to determine path for npmrc"))
-			})
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
 		})
 
-		context("when determining the path for the yarnrc fails", func() {
+		context("when the policy is provided through BP_YARN_LICENSE_POLICY", func() {
 			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "yarnrc" {
-						return "", errors.New("failed to determine path for yarnrc")
-					}
-					return "", nil
-				}
+				t.Setenv("BP_YARN_LICENSE_POLICY", policyPath)
 			})
 
-			it("errors", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Layers:     packit.Layers{Path: layersDir},
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
-					},
-				})
-				Expect(err).To(MatchError("failed to determine path for yarnrc"))
+			it("fails the build and prints a table of the violations", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed license policy check: 3 packages have forbidden or missing licenses"))
+
+				Expect(buffer.String()).To(ContainSubstring("Checking installed packages against the license policy"))
+				Expect(buffer.String()).To(ContainSubstring("Found 3 license policy violations:"))
+				Expect(buffer.String()).To(MatchRegexp(`PACKAGE\s+VERSION\s+LICENSE\s+REASON`))
+				Expect(buffer.String()).To(MatchRegexp(`(?m)combined-package\s+1\.0\.0\s+MIT AND AGPL-3\.0-only\s+AGPL-3\.0-only is denied \(AGPL-\*\)$`))
+				Expect(buffer.String()).To(MatchRegexp(`gpl-package\s+1\.0\.0\s+GPL-2\.0\s+GPL-2\.0 is not allowed`))
+				Expect(buffer.String()).To(MatchRegexp(`unlicensed-package\s+1\.0\.0\s+-\s+no license information`))
+				Expect(buffer.String()).NotTo(ContainSubstring("dual-package"))
+				Expect(buffer.String()).NotTo(ContainSubstring("bsd-package"))
 			})
 		})
 
-		context("when .npmrc service binding symlink cannot be created", func() {
+		context("when the policy is provided through a binding", func() {
 			it.Before(func() {
 				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "npmrc" {
-						return "some-path/.npmrc", nil
+					if typ == "license-policy" {
+						return policyPath, nil
 					}
 					return "", nil
 				}
 
-				symlinker.LinkCall.Stub = func(o string, n string) error {
-					if strings.Contains(o, ".npmrc") {
-						return errors.New("symlinking .npmrc error")
-					}
-					return nil
-				}
+				Expect(os.WriteFile(policyPath, []byte(`deny = ["AGPL-*", "GPL-*"]`), 0600)).To(Succeed())
 			})
 
-			it("errors", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Layers:     packit.Layers{Path: layersDir},
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
-					},
-				})
-				Expect(err).To(MatchError(ContainSubstring("symlinking .npmrc error")))
+			it("evaluates the policy from the binding", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed license policy check: 3 packages have forbidden or missing licenses"))
+				Expect(buffer.String()).To(MatchRegexp(`gpl-package\s+1\.0\.0\s+GPL-2\.0\s+GPL-2\.0 is denied \(GPL-\*\)`))
 			})
 		})
 
-		context("when .yarnrc service binding symlink cannot be created", func() {
+		context("when every package complies", func() {
 			it.Before(func() {
-				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
-					if typ == "yarnrc" {
-						return "some-path/.yarnrc", nil
-					}
-					return "", nil
+				t.Setenv("BP_YARN_LICENSE_POLICY", policyPath)
+				Expect(os.WriteFile(policyPath, []byte(`deny = ["WTFPL"]`), 0600)).To(Succeed())
+
+				installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+					dir := filepath.Join(modulesLayerPath, "node_modules", "mit-package")
+					Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "mit-package", "version": "1.0.0", "license": "MIT"}`), 0600)).To(Succeed())
+					return nil, nil
 				}
+			})
 
-				symlinker.LinkCall.Stub = func(o string, n string) error {
-					if strings.Contains(o, ".yarnrc") {
-						return errors.New("symlinking .yarnrc error")
-					}
-					return nil
-				}
+			it("succeeds", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("All 1 packages comply with the license policy"))
 			})
 
-			it("errors", func() {
-				_, err := build(packit.BuildContext{
-					WorkingDir: workingDir,
-					CNBPath:    cnbDir,
-					Layers:     packit.Layers{Path: layersDir},
-					Plan: packit.BuildpackPlan{
-						Entries: []packit.BuildpackPlanEntry{
-							{Name: "node_modules"},
-						},
-					},
+			context("when the launch layer is reused", func() {
+				var digest string
+
+				it.Before(func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+					digest = result.Layers[0].Metadata["license_policy"].(string)
+					Expect(digest).NotTo(BeEmpty())
+
+					installProcess.ShouldRunCall.Stub = nil
+					installProcess.ShouldRunCall.Returns.Run = false
+					installProcess.ExecuteCall.CallCount = 0
+				})
+
+				context("when the policy is unchanged", func() {
+					it.Before(func() {
+						Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), []byte(fmt.Sprintf("[metadata]\ncache_sha = \"some-awesome-shasum\"\nlicense_policy = %q\n", digest)), 0600)).To(Succeed())
+					})
+
+					it("reuses the layer", func() {
+						_, err := build(buildContext)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+					})
+				})
+
+				context("when the policy changed", func() {
+					it.Before(func() {
+						Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), []byte(fmt.Sprintf("[metadata]\ncache_sha = \"some-awesome-shasum\"\nlicense_policy = %q\n", digest)), 0600)).To(Succeed())
+						Expect(os.WriteFile(policyPath, []byte(`deny = ["MIT"]`), 0600)).To(Succeed())
+					})
+
+					it("reinstalls the layer and checks it against the new policy", func() {
+						_, err := build(buildContext)
+						Expect(err).To(MatchError("failed license policy check: 1 packages have forbidden or missing licenses"))
+						Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+						Expect(buffer.String()).To(ContainSubstring("License policy changed since the layer was installed, reinstalling to check it"))
+					})
 				})
-				Expect(err).To(MatchError(ContainSubstring("symlinking .yarnrc error")))
 			})
 		})
 
-		context("during the build installation process", func() {
-			it.Before(func() {
-				entryResolver.MergeLayerTypesCall.Returns.Build = true
+		context("failure cases", func() {
+			context("when the policy cannot be parsed", func() {
+				it.Before(func() {
+					t.Setenv("BP_YARN_LICENSE_POLICY", policyPath)
+					Expect(os.WriteFile(policyPath, []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to parse license policy %s", policyPath))))
+				})
 			})
-			context("when the layer cannot be retrieved", func() {
+
+			context("when the policy contains a malformed pattern", func() {
 				it.Before(func() {
-					Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), nil, 0000)).To(Succeed())
+					t.Setenv("BP_YARN_LICENSE_POLICY", policyPath)
+					Expect(os.WriteFile(policyPath, []byte(`deny = ["["]`), 0600)).To(Succeed())
 				})
 
 				it("returns an error", func() {
-					_, err := build(packit.BuildContext{
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring(`malformed pattern "["`)))
+				})
+			})
+
+			context("when determining the path for the license policy fails", func() {
+				it.Before(func() {
+					configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+						if typ == "license-policy" {
+							return "", errors.New("failed to determine path for license-policy")
+						}
+						return "", nil
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to determine path for license-policy"))
+				})
+			})
+		})
+	})
+
+	context("when an advisory database is bound", func() {
+		var (
+			buildContext   packit.BuildContext
+			advisoriesPath string
+		)
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			projectDir := filepath.Join(workingDir, "some-project-dir")
+			Expect(os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(`{
+				"dependencies": { "left-pad": "^1.3.0", "minimist": "^1.2.0" },
+				"devDependencies": { "lodash": "^4.17.0" }
+			}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(projectDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+left-pad@^1.3.0:
+  version "1.3.0"
+
+lodash@^4.17.0:
+  version "4.17.20"
+
+minimist@^1.2.0:
+  version "1.2.8"
+`), 0600)).To(Succeed())
+
+			advisoriesPath = filepath.Join(t.TempDir(), "advisories")
+			Expect(os.WriteFile(advisoriesPath, []byte(`[
+				{
+					"id": "GHSA-lodash",
+					"summary": "Prototype pollution in lodash",
+					"aliases": ["CVE-2021-23337"],
+					"affected": [{
+						"package": { "ecosystem": "npm", "name": "lodash" },
+						"ranges": [{ "type": "SEMVER", "events": [{ "introduced": "0" }, { "fixed": "4.17.21" }] }]
+					}],
+					"database_specific": { "severity": "HIGH" }
+				},
+				{
+					"id": "GHSA-left-pad",
+					"affected": [{
+						"package": { "ecosystem": "npm", "name": "left-pad" },
+						"versions": ["1.3.0"]
+					}],
+					"database_specific": { "severity": "LOW" }
+				},
+				{
+					"id": "GHSA-minimist",
+					"affected": [{
+						"package": { "ecosystem": "npm", "name": "minimist" },
+						"ranges": [{ "type": "SEMVER", "events": [{ "introduced": "0" }, { "fixed": "1.2.6" }] }]
+					}],
+					"database_specific": { "severity": "CRITICAL" }
+				}
+			]`), 0600)).To(Succeed())
+
+			configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+				if typ == "osv-advisories" {
+					return advisoriesPath, nil
+				}
+				return "", nil
+			}
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("fails the build when a vulnerability is at or above the default level", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(`failed audit: 1 vulnerabilities at or above severity "high" (BP_YARN_AUDIT_LEVEL)`))
+
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+
+			Expect(buffer.String()).To(ContainSubstring("Auditing yarn.lock against the advisory database"))
+			Expect(buffer.String()).To(ContainSubstring("Checked 3 packages against 3 advisories"))
+			Expect(buffer.String()).To(ContainSubstring("Found 2 vulnerabilities:"))
+			Expect(buffer.String()).To(ContainSubstring("lodash@4.17.20: GHSA-lodash (high) Prototype pollution in lodash, fixed in 4.17.21"))
+			Expect(buffer.String()).To(ContainSubstring("left-pad@1.3.0: GHSA-left-pad (low)"))
+			Expect(buffer.String()).NotTo(ContainSubstring("GHSA-minimist"))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "audit", "report.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"level": "high",
+				"advisories": 3,
+				"packages": 3,
+				"findings": [
+					{
+						"id": "GHSA-lodash",
+						"aliases": ["CVE-2021-23337"],
+						"package": "lodash",
+						"version": "4.17.20",
+						"severity": "high",
+						"scope": "development",
+						"summary": "Prototype pollution in lodash",
+						"fixed": ["4.17.21"]
+					},
+					{
+						"id": "GHSA-left-pad",
+						"package": "left-pad",
+						"version": "1.3.0",
+						"severity": "low",
+						"scope": "production"
+					}
+				]
+			}`))
+		})
+
+		context("when BP_YARN_AUDIT_LEVEL is above every finding", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_AUDIT_LEVEL", "critical")
+			})
+
+			it("warns and exposes the report in the image", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring(`All vulnerabilities are below severity "critical" (BP_YARN_AUDIT_LEVEL), continuing`))
+
+				Expect(result.Layers).To(HaveLen(3))
+				auditLayer := result.Layers[1]
+				Expect(auditLayer.Name).To(Equal("audit"))
+				Expect(auditLayer.Launch).To(BeTrue())
+				Expect(auditLayer.LaunchEnv).To(Equal(packit.Environment{
+					"YARN_AUDIT_REPORT.default": filepath.Join(layersDir, "audit", "report.json"),
+				}))
+			})
+		})
+
+		context("when the advisory database is a zip archive", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_AUDIT_LEVEL", "low")
+
+				file, err := os.Create(advisoriesPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				archive := zip.NewWriter(file)
+				writer, err := archive.Create("GHSA-left-pad.json")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = writer.Write([]byte(`{
+					"id": "GHSA-left-pad",
+					"affected": [{
+						"package": { "ecosystem": "npm", "name": "left-pad" },
+						"ranges": [{ "type": "SEMVER", "events": [{ "introduced": "1.0.0" }, { "last_affected": "1.3.0" }] }]
+					}]
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(archive.Close()).To(Succeed())
+				Expect(file.Close()).To(Succeed())
+			})
+
+			it("reads the advisories from the archive", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed audit: 1 vulnerabilities at or above severity "low" (BP_YARN_AUDIT_LEVEL)`))
+				Expect(buffer.String()).To(ContainSubstring("left-pad@1.3.0: GHSA-left-pad (unknown)"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_YARN_AUDIT_LEVEL is unknown", func() {
+				it.Before(func() {
+					t.Setenv("BP_YARN_AUDIT_LEVEL", "severe")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(`failed to parse BP_YARN_AUDIT_LEVEL: unknown level "severe", supported levels are low, moderate, high, critical`))
+				})
+			})
+
+			context("when the advisory database is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(advisoriesPath, []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse advisory")))
+				})
+			})
+		})
+	})
+
+	context("when the launch layer is installed", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte("some-yarn-lock-content"), 0600)).To(Succeed())
+
+			installProcess.VersionCall.Returns.String = "1.22.22"
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				for _, name := range []string{"left-pad", "native-package"} {
+					dir := filepath.Join(modulesLayerPath, "node_modules", name)
+					Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(dir, "package.json"), []byte(fmt.Sprintf(`{"name": %q, "version": "1.0.0"}`, name)), 0600)).To(Succeed())
+				}
+
+				Expect(os.MkdirAll(filepath.Join(modulesLayerPath, "node_modules", "native-package", "build", "Release"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(modulesLayerPath, "node_modules", "native-package", "build", "Release", "addon.node"), []byte("0123456789"), 0600)).To(Succeed())
+
+				return nil, nil
+			}
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("labels the image with a summary of the launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.VersionCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "some-project-dir")))
+
+			// sha256 of "some-yarn-lock-content"
+			Expect(result.Launch.Labels).To(Equal(map[string]string{
+				"io.paketo.yarn-install.lockfile-digest":     "sha256:3ed7a768605cf2fa7ae0ec5b3c1a0e5756ccbaff2b481c51809fa7e875210db9",
+				"io.paketo.yarn-install.production-packages": "2",
+				"io.paketo.yarn-install.node-modules-size":   "96",
+				"io.paketo.yarn-install.native-addons":       "true",
+				"io.paketo.yarn-install.yarn-version":        "1.22.22",
+			}))
+		})
+
+		context("when the launch layer is reused", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = nil
+				installProcess.ShouldRunCall.Returns.Run = false
+
+				Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), []byte(`[metadata]
+cache_sha = "some-awesome-shasum"
+
+[metadata.labels]
+"io.paketo.yarn-install.production-packages" = "2"
+"io.paketo.yarn-install.yarn-version" = "1.22.22"
+`), 0600)).To(Succeed())
+			})
+
+			it("labels the image with the summary recorded by the previous build", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.VersionCall.CallCount).To(Equal(0))
+				Expect(result.Launch.Labels).To(Equal(map[string]string{
+					"io.paketo.yarn-install.production-packages": "2",
+					"io.paketo.yarn-install.yarn-version":        "1.22.22",
+				}))
+			})
+		})
+
+		context("when the yarn version cannot be determined", func() {
+			it.Before(func() {
+				installProcess.VersionCall.Returns.Error = errors.New("failed to execute yarn --version")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed to execute yarn --version"))
+			})
+		})
+	})
+
+	context("when writing the build report", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			Expect(os.MkdirAll(filepath.Join(layersDir, "launch-modules"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), []byte(`[metadata]
+cache_sha = "some-previous-shasum"
+`), 0600)).To(Succeed())
+
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				dir := filepath.Join(modulesLayerPath, "node_modules", "left-pad")
+				Expect(os.MkdirAll(dir, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "left-pad", "version": "1.3.0"}`), 0600)).To(Succeed())
+
+				return []string{fmt.Sprintf("yarn install --launch=%t", launch)}, nil
+			}
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("describes every modules layer in each of them", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, name := range []string{"build-modules", "launch-modules"} {
+				var report yarninstall.BuildReport
+				_, err = toml.DecodeFile(filepath.Join(layersDir, name, "build-report.toml"), &report)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Layers).To(HaveLen(2))
+
+				buildLayer := report.Layers[0]
+				Expect(buildLayer.Name).To(Equal("build-modules"))
+				Expect(buildLayer.Status).To(Equal("rebuilt"))
+				Expect(buildLayer.CacheSHA).To(Equal("some-awesome-shasum"))
+				Expect(buildLayer.PreviousCacheSHA).To(BeEmpty())
+				Expect(buildLayer.CacheInputs).To(ContainElements("yarn.lock", "package.json"))
+				Expect(buildLayer.InstallDuration).NotTo(BeEmpty())
+				Expect(buildLayer.Commands).To(Equal([]string{"yarn install --launch=false"}))
+				Expect(buildLayer.Packages).To(Equal(1))
+				Expect(buildLayer.Size).To(Equal(int64(40)))
+
+				launchLayer := report.Layers[1]
+				Expect(launchLayer.Name).To(Equal("launch-modules"))
+				Expect(launchLayer.Status).To(Equal("rebuilt"))
+				Expect(launchLayer.CacheSHA).To(Equal("some-awesome-shasum"))
+				Expect(launchLayer.PreviousCacheSHA).To(Equal("some-previous-shasum"))
+				Expect(launchLayer.Commands).To(Equal([]string{"yarn install --launch=true"}))
+				Expect(launchLayer.Packages).To(Equal(1))
+			}
+		})
+
+		context("when the launch layer is reused", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = func(_ string, metadata map[string]interface{}) (bool, string, error) {
+					if metadata["cache_sha"] == "some-previous-shasum" {
+						return false, "", nil
+					}
+					return true, "some-awesome-shasum", nil
+				}
+			})
+
+			it("does not write into the reused launch layer", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(layersDir, "launch-modules", "build-report.toml")).NotTo(BeAnExistingFile())
+
+				var report yarninstall.BuildReport
+				_, err = toml.DecodeFile(filepath.Join(layersDir, "build-modules", "build-report.toml"), &report)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Layers[1].Name).To(Equal("launch-modules"))
+				Expect(report.Layers[1].Status).To(Equal("reused"))
+				Expect(report.Layers[1].CacheSHA).To(Equal("some-previous-shasum"))
+				Expect(report.Layers[1].Commands).To(BeEmpty())
+			})
+		})
+	})
+
+	context("when native addons are installed", func() {
+		var (
+			buildContext packit.BuildContext
+			libraryDir   string
+		)
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			libraryDir = t.TempDir()
+			Expect(os.WriteFile(filepath.Join(libraryDir, "libstdc++.so.6"), nil, 0600)).To(Succeed())
+			t.Setenv("LD_LIBRARY_PATH", libraryDir)
+
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				release := filepath.Join(modulesLayerPath, "node_modules", "some-addon", "build", "Release")
+				writeNativeAddon(t, filepath.Join(release, "addon.node"), elf.EM_X86_64, []string{"libstdc++.so.6", "libbundled.so.1", "libmissing.so.1"}, "$ORIGIN/../../lib", []string{"node_register_module_v115"})
+				Expect(os.MkdirAll(filepath.Join(modulesLayerPath, "node_modules", "some-addon", "lib"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(modulesLayerPath, "node_modules", "some-addon", "lib", "libbundled.so.1"), nil, 0600)).To(Succeed())
+
+				writeNativeAddon(t, filepath.Join(modulesLayerPath, "node_modules", "napi-addon", "prebuilds", "linux-arm64", "napi.node"), elf.EM_AARCH64, nil, "", []string{"napi_register_module_v1"})
+
+				return nil, nil
+			}
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "io.buildpacks.stacks.jammy",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("records the addons in the layer metadata and warns about missing libraries", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			layer := result.Layers[0]
+			Expect(layer.Metadata["node_abi"]).To(Equal("115"))
+			Expect(layer.Metadata["native_addons"]).To(Equal([]yarninstall.NativeAddon{
+				{
+					Path:              filepath.Join("napi-addon", "prebuilds", "linux-arm64", "napi.node"),
+					Format:            "elf",
+					Arch:              "arm64",
+					NodeModuleVersion: "napi",
+				},
+				{
+					Path:              filepath.Join("some-addon", "build", "Release", "addon.node"),
+					Format:            "elf",
+					Arch:              "amd64",
+					NodeModuleVersion: "115",
+					Needed:            []string{"libstdc++.so.6", "libbundled.so.1", "libmissing.so.1"},
+					Missing:           []string{"libmissing.so.1"},
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Inspecting native addons"))
+			Expect(buffer.String()).To(ContainSubstring("napi-addon/prebuilds/linux-arm64/napi.node (arm64, Node-API)"))
+			Expect(buffer.String()).To(ContainSubstring("some-addon/build/Release/addon.node (amd64, NODE_MODULE_VERSION 115)"))
+			Expect(buffer.String()).To(ContainSubstring("Warning: some-addon/build/Release/addon.node needs libmissing.so.1, which is not available in the build environment"))
+		})
+
+		context("when the run image is a tiny stack", func() {
+			it.Before(func() {
+				buildContext.Stack = "io.buildpacks.stacks.jammy.tiny"
+			})
+
+			it("warns about every library the tiny stack does not ship", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				addons := result.Layers[0].Metadata["native_addons"].([]yarninstall.NativeAddon)
+				Expect(addons[1].Missing).To(Equal([]string{"libstdc++.so.6", "libmissing.so.1"}))
+				Expect(buffer.String()).To(ContainSubstring("Warning: some-addon/build/Release/addon.node needs libstdc++.so.6, which is not available in the tiny run image"))
+			})
+		})
+	})
+
+	context("when packages compile native addons but the build tools are missing", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+
+microtime@^3.0.0:
+  version "3.1.1"
+  dependencies:
+    node-addon-api "^5.0.0"
+`), 0644)).To(Succeed())
+
+			t.Setenv("PATH", t.TempDir())
+		})
+
+		it("fails before installing with an explanation", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+			})
+			Expect(err).To(MatchError(ContainSubstring("failed: the following packages compile native addons with node-gyp, which needs python, make and a C++ compiler:\n  - microtime@3.1.1\n")))
+			Expect(err).To(MatchError(ContainSubstring("Missing from the build environment: python, make, a C++ compiler.")))
+			Expect(err).To(MatchError(ContainSubstring(`optionally requires "cpython" in the build plan`)))
+
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+		})
+
+		context("when the package is only an optional dependency", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "package.json"), []byte(`{"dependencies": {"ssh2": "^1.0.0"}}`), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+
+cpu-features@~0.0.9:
+  version "0.0.9"
+  dependencies:
+    buildcheck "~0.0.6"
+    nan "^2.17.0"
+
+ssh2@^1.0.0:
+  version "1.15.0"
+  optionalDependencies:
+    cpu-features "~0.0.9"
+`), 0644)).To(Succeed())
+			})
+
+			it("warns and installs anyway", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+
+				Expect(buffer.String()).To(ContainSubstring("Warning: the following optional packages compile native addons with node-gyp, which needs python, make, a C++ compiler; yarn installs without them if they fail to compile:\n      cpu-features@0.0.9\n"))
+			})
+		})
+
+		context("when the modules layers are reused", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = nil
+				installProcess.ShouldRunCall.Returns.Run = false
+			})
+
+			it("does not check the build tools", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_YARN_SKIP_BUILD_TOOLS_CHECK is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_SKIP_BUILD_TOOLS_CHECK", "true")
+			})
+
+			it("installs anyway", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+			})
+		})
+	})
+
+	context("when native builds download headers or prebuilt binaries", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+
+			installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+				Expect(os.MkdirAll(filepath.Join(cacheLayerPath, "node-gyp", "20.11.0"), os.ModePerm)).To(Succeed())
+				return nil, nil
+			}
+		})
+
+		it("keeps the downloads in a cache layer", func() {
+			result, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(installProcess.ExecuteCall.Receives.CacheLayerPath).To(Equal(filepath.Join(layersDir, "native-build-cache")))
+
+			var cacheLayer packit.Layer
+			for _, layer := range result.Layers {
+				if layer.Name == "native-build-cache" {
+					cacheLayer = layer
+				}
+			}
+			Expect(cacheLayer.Path).To(Equal(filepath.Join(layersDir, "native-build-cache")))
+			Expect(cacheLayer.Cache).To(BeTrue())
+			Expect(cacheLayer.Build).To(BeFalse())
+			Expect(cacheLayer.Launch).To(BeFalse())
+		})
+
+		context("when the build install caches downloaded binaries", func() {
+			it.Before(func() {
+				installProcess.ExecuteCall.Stub = func(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) ([]string, error) {
+					if !launch {
+						Expect(os.MkdirAll(filepath.Join(cacheLayerPath, "cypress", "13.6.0"), os.ModePerm)).To(Succeed())
+					}
+					return nil, nil
+				}
+			})
+
+			it("points the build environment at them", func() {
+				result, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				buildLayer := result.Layers[0]
+				Expect(buildLayer.Name).To(Equal("build-modules"))
+				Expect(buildLayer.BuildEnv["CYPRESS_CACHE_FOLDER.default"]).To(Equal(filepath.Join(layersDir, "native-build-cache", "cypress")))
+				Expect(buildLayer.BuildEnv).NotTo(HaveKey("PUPPETEER_CACHE_DIR.default"))
+
+				Expect(result.Layers[2].Name).To(Equal("native-build-cache"))
+			})
+		})
+	})
+
+	context("when the Node ABI changed since the native addons were built", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			installProcess.ShouldRunCall.Stub = nil
+			installProcess.ShouldRunCall.Returns.Run = false
+
+			rebuildProcess.RebuildCall.Returns.StringSlice = []string{"npm rebuild @scope/some-addon other-addon"}
+
+			metadata := []byte(`[metadata]
+cache_sha = "some-awesome-shasum"
+node_abi = "108"
+
+[[metadata.native_addons]]
+path = "@scope/some-addon/build/Release/addon.node"
+format = "elf"
+
+[[metadata.native_addons]]
+path = "@scope/some-addon/build/Release/other.node"
+format = "elf"
+
+[[metadata.native_addons]]
+path = "parent/node_modules/other-addon/prebuilds/addon.node"
+format = "elf"
+`)
+			Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), metadata, 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(layersDir, "launch-modules.toml"), metadata, 0600)).To(Succeed())
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "1.2.3",
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+			}
+
+			t.Setenv("BP_DISABLE_SBOM", "true")
+		})
+
+		context("when the build layer is reused", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Build = true
+			})
+
+			it("keeps the layer and rebuilds only the packages with native addons", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+				Expect(rebuildProcess.RebuildCall.Receives.ModulesLayerPath).To(Equal(filepath.Join(layersDir, "build-modules")))
+				Expect(rebuildProcess.RebuildCall.Receives.CacheLayerPath).To(Equal(filepath.Join(layersDir, "native-build-cache")))
+				Expect(rebuildProcess.RebuildCall.Receives.Packages).To(Equal([]string{"@scope/some-addon", "other-addon"}))
+
+				layer := result.Layers[0]
+				Expect(layer.Name).To(Equal("build-modules"))
+				Expect(layer.Metadata["cache_sha"]).To(Equal("some-awesome-shasum"))
+				Expect(layer.Metadata["node_abi"]).To(Equal("115"))
+
+				Expect(buffer.String()).To(ContainSubstring("Rebuilding native addons for NODE_MODULE_VERSION 115 (was 108)"))
+				Expect(buffer.String()).To(ContainSubstring("@scope/some-addon"))
+
+				var report yarninstall.BuildReport
+				_, err = toml.DecodeFile(filepath.Join(layersDir, "build-modules", "build-report.toml"), &report)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Layers[0].Status).To(Equal("rebuilt-native"))
+				Expect(report.Layers[0].Commands).To(Equal([]string{"npm rebuild @scope/some-addon other-addon"}))
+			})
+
+			context("when BP_YARN_IGNORE_SCRIPTS is set", func() {
+				it.Before(func() {
+					t.Setenv("BP_YARN_IGNORE_SCRIPTS", "true")
+					t.Setenv("BP_YARN_ALLOWED_SCRIPTS", "other-addon")
+				})
+
+				it("rebuilds only the allowed packages", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(rebuildProcess.RebuildCall.Receives.Packages).To(Equal([]string{"other-addon"}))
+					Expect(buffer.String()).To(ContainSubstring("Not rebuilding native addons of packages that are not in BP_YARN_ALLOWED_SCRIPTS: @scope/some-addon"))
+				})
+			})
+
+			context("when the ABI is unchanged", func() {
+				it.Before(func() {
+					rebuildProcess.NodeABICall.Returns.String = "108"
+				})
+
+				it("reuses the layer", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(rebuildProcess.RebuildCall.CallCount).To(Equal(0))
+					Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				})
+			})
+
+			context("when the rebuild fails", func() {
+				it.Before(func() {
+					rebuildProcess.RebuildCall.Returns.Error = errors.New("failed to rebuild")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to rebuild"))
+				})
+			})
+		})
+
+		context("when the launch layer is reused", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			})
+
+			it("reinstalls the layer, whose contents are not cached", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(rebuildProcess.RebuildCall.CallCount).To(Equal(0))
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+
+				layer := result.Layers[0]
+				Expect(layer.Name).To(Equal("launch-modules"))
+				Expect(layer.Metadata["cache_sha"]).To(Equal("some-awesome-shasum"))
+
+				Expect(buffer.String()).To(ContainSubstring("Native addons were built for NODE_MODULE_VERSION 108, reinstalling for 115"))
+			})
+		})
+	})
+
+	context("when BP_SBOM_FORMATS is set", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			t.Setenv("BP_SBOM_FORMATS", "cyclonedx, application/vnd.syft+json")
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+		})
+
+		it("only generates the selected formats", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			formats := result.Layers[0].SBOM.Formats()
+			Expect(formats).To(HaveLen(2))
+			Expect(formats[0].Extension).To(Equal("cdx.json"))
+			Expect(formats[1].Extension).To(Equal("syft.json"))
+
+			Expect(buffer.String()).NotTo(ContainSubstring("application/spdx+json"))
+		})
+
+		context("when a selected format is unknown", func() {
+			it.Before(func() {
+				t.Setenv("BP_SBOM_FORMATS", "cyclonedx,some-format")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to parse BP_SBOM_FORMATS: unknown SBOM format "some-format", supported formats are application/vnd.cyclonedx+json, application/spdx+json, application/vnd.syft+json`))
+			})
+		})
+	})
+
+	context("when BP_YARN_SBOM_GENERATOR is set", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			t.Setenv("BP_YARN_SBOM_GENERATOR", "lockfile")
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+			}
+		})
+
+		it("generates the SBOM from the lockfile instead of scanning with syft", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(0))
+			Expect(result.Layers[0].SBOM.Formats()).To(HaveLen(1))
+		})
+
+		context("when the value is not supported", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_SBOM_GENERATOR", "some-generator")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(`failed to parse BP_YARN_SBOM_GENERATOR: unsupported value "some-generator", must be one of 'syft' or 'lockfile'`))
+			})
+		})
+	})
+
+	context("when an SBOM was cached by a previous build", func() {
+		var buildContext packit.BuildContext
+
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Build = true
+
+			buildContext = packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:        "Some Buildpack",
+					Version:     "1.2.3",
+					SBOMFormats: []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"},
+				},
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				Platform: packit.Platform{
+					Path: "some-platform-path",
+				},
+			}
+
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+
+			Expect(result.Layers).To(HaveLen(2))
+			Expect(result.Layers[1].Name).To(Equal("sbom-cache"))
+			Expect(filepath.Join(layersDir, "sbom-cache", "build-modules", "metadata.toml")).To(BeARegularFile())
+			Expect(filepath.Join(layersDir, "sbom-cache", "build-modules", "sbom.cdx.json")).To(BeARegularFile())
+
+			buffer.Reset()
+		})
+
+		context("when the layer is rebuilt from identical inputs", func() {
+			it("reuses the cached SBOM instead of rescanning the layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+
+				layer := result.Layers[0]
+				Expect(layer.SBOM.Formats()).To(HaveLen(3))
+				Expect(layer.SBOM.Formats()[0].Extension).To(Equal("cdx.json"))
+				Expect(layer.SBOM.Formats()[1].Extension).To(Equal("spdx.json"))
+				Expect(layer.SBOM.Formats()[2].Extension).To(Equal("syft.json"))
+
+				content, err := io.ReadAll(layer.SBOM.Formats()[0].Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"bomFormat": "CycloneDX"`))
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached SBOM for build-modules (cache_sha some-awesome-shasum)"))
+				Expect(buffer.String()).NotTo(ContainSubstring("Generating SBOM"))
+			})
+		})
+
+		context("when the SBOM generator has changed", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_SBOM_GENERATOR", "lockfile")
+			})
+
+			it("does not reuse the SBOM of the other generator", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+				Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached SBOM"))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "sbom-cache", "build-modules", "metadata.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`generator = "lockfile"`))
+			})
+		})
+
+		context("when the inputs of the layer have changed", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = func(string, map[string]interface{}) (bool, string, error) {
+					return true, "some-other-shasum", nil
+				}
+			})
+
+			it("generates and caches a new SBOM", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(2))
+				Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached SBOM"))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "sbom-cache", "build-modules", "metadata.toml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`sha = "some-other-shasum"`))
+			})
+		})
+
+		context("when the layer itself is reused", func() {
+			it.Before(func() {
+				installProcess.ShouldRunCall.Stub = nil
+				installProcess.ShouldRunCall.Returns.Run = false
+
+				Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), []byte(`[metadata]
+cache_sha = "some-awesome-shasum"
+`), 0600)).To(Succeed())
+			})
+
+			it("attaches the cached SBOM to the reused layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(sbomGenerator.GenerateCall.CallCount).To(Equal(1))
+
+				layer := result.Layers[0]
+				Expect(layer.Name).To(Equal("build-modules"))
+				Expect(layer.SBOM.Formats()).To(HaveLen(3))
+
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached layer"))
+				Expect(buffer.String()).To(ContainSubstring("Reusing cached SBOM for build-modules (cache_sha some-awesome-shasum)"))
+			})
+		})
+
+		context("when BP_DISABLE_SBOM is set", func() {
+			it.Before(func() {
+				t.Setenv("BP_DISABLE_SBOM", "true")
+			})
+
+			it("does not use the SBOM cache", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(result.Layers[0].SBOM).To(BeNil())
+				Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached SBOM"))
+			})
+		})
+	})
+
+	context("when BP_YARN_ALLOWED_REGISTRIES is set", func() {
+		it.Before(func() {
+			entryResolver.MergeLayerTypesCall.Returns.Launch = true
+			t.Setenv("BP_YARN_ALLOWED_REGISTRIES", "registry.yarnpkg.com, *.example.com, http://insecure.example.org")
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+left-pad@^1.3.0:
+  version "1.3.0"
+  resolved "https://registry.yarnpkg.com/left-pad/-/left-pad-1.3.0.tgz#5b8a3a7765dfe001261dde915589e782f8c94d1e"
+
+right-pad@^1.0.0:
+  version "1.0.1"
+  resolved "https://npm.example.com/right-pad/-/right-pad-1.0.1.tgz"
+
+center-pad@^1.0.0:
+  version "1.0.0"
+  resolved "http://insecure.example.org/center-pad/-/center-pad-1.0.0.tgz"
+`), os.ModePerm)).To(Succeed())
+		})
+
+		it("checks the resolved URLs before installing", func() {
+			_, err := build(packit.BuildContext{
+				WorkingDir: workingDir,
+				CNBPath:    cnbDir,
+				Layers:     packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node_modules"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("Checking resolved URLs against BP_YARN_ALLOWED_REGISTRIES"))
+			Expect(buffer.String()).To(ContainSubstring("All resolved URLs are allowed"))
+			Expect(installProcess.ExecuteCall.CallCount).To(Equal(1))
+		})
+
+		context("when packages are resolved from registries that are not allowed", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_ALLOWED_REGISTRIES", "registry.yarnpkg.com,npm.example.com,insecure.example.org")
+			})
+
+			it("returns an error listing the offending packages", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("not allowed by BP_YARN_ALLOWED_REGISTRIES")))
+				Expect(err).To(MatchError(ContainSubstring("  - center-pad@1.0.0 from insecure.example.org (insecure http:// resolution)")))
+				Expect(err).NotTo(MatchError(ContainSubstring("left-pad")))
+				Expect(err).NotTo(MatchError(ContainSubstring("right-pad")))
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when BP_YARN_REGISTRY_MIRROR maps the registries to a mirror", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_ALLOWED_REGISTRIES", "artifactory.example.com, http://insecure.example.org")
+				t.Setenv("BP_YARN_REGISTRY_MIRROR", "registry.yarnpkg.com=https://artifactory.example.com/api/npm/npm-remote,npm.example.com=https://artifactory.example.com/api/npm/private")
+			})
+
+			it("checks the URLs the mirror mapping resolves to", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(ContainSubstring("All resolved URLs are allowed"))
+			})
+		})
+
+		context("when packages have resolutions without a registry host", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-project-dir", "yarn.lock"), []byte(`# yarn lockfile v1
+
+left-pad@^1.3.0:
+  version "1.3.0"
+  resolved "https://registry.yarnpkg.com/left-pad/-/left-pad-1.3.0.tgz#5b8a3a7765dfe001261dde915589e782f8c94d1e"
+
+private-lib@git+ssh://git@github.com:org/private-lib.git#abc123:
+  version "1.0.0"
+  resolved "git+ssh://git@github.com:org/private-lib.git#abc123"
+
+local-lib@file:./vendor/local-lib-1.0.0.tgz:
+  version "1.0.0"
+  resolved "file:./vendor/local-lib-1.0.0.tgz#def456"
+`), os.ModePerm)).To(Succeed())
+			})
+
+			it("reports them as violations", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("not allowed by BP_YARN_ALLOWED_REGISTRIES")))
+				Expect(err).To(MatchError(ContainSubstring(`  - private-lib@1.0.0 from unparseable resolution "git+ssh://git@github.com:org/private-lib.git#abc123"`)))
+				Expect(err).To(MatchError(ContainSubstring(`  - local-lib@1.0.0 from unparseable resolution "file:./vendor/local-lib-1.0.0.tgz#def456"`)))
+				Expect(err).NotTo(MatchError(ContainSubstring("left-pad")))
+
+				Expect(installProcess.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+
+		context("when the project path parser provided fails", func() {
+			it.Before(func() {
+				t.Setenv("BP_NODE_PROJECT_PATH", "does_not_exist")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					Layers:     packit.Layers{Path: layersDir},
+					CNBPath:    cnbDir,
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("could not find project path \"%s/does_not_exist\": stat %s/does_not_exist: no such file or directory", workingDir, workingDir))))
+			})
+		})
+
+		context("when BP_YARN_ALLOWED_REGISTRIES contains a malformed pattern", func() {
+			it.Before(func() {
+				t.Setenv("BP_YARN_ALLOWED_REGISTRIES", "[registry.example.com")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_YARN_ALLOWED_REGISTRIES pattern")))
+			})
+		})
+
+		context("when determining the path for the npmrc fails", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+					if typ == "npmrc" {
+						return "", errors.New("failed to determine path for npmrc")
+					}
+					return "", nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError("failed to determine path for npmrc"))
+			})
+		})
+
+		context("when determining the path for the yarnrc fails", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+					if typ == "yarnrc" {
+						return "", errors.New("failed to determine path for yarnrc")
+					}
+					return "", nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError("failed to determine path for yarnrc"))
+			})
+		})
+
+		context("when .npmrc service binding symlink cannot be created", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+					if typ == "npmrc" {
+						return "some-path/.npmrc", nil
+					}
+					return "", nil
+				}
+
+				symlinker.LinkCall.Stub = func(o string, n string) error {
+					if strings.Contains(o, ".npmrc") {
+						return errors.New("symlinking .npmrc error")
+					}
+					return nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("symlinking .npmrc error")))
+			})
+		})
+
+		context("when .yarnrc service binding symlink cannot be created", func() {
+			it.Before(func() {
+				configurationManager.DeterminePathCall.Stub = func(typ, platform, entry string) (string, error) {
+					if typ == "yarnrc" {
+						return "some-path/.yarnrc", nil
+					}
+					return "", nil
+				}
+
+				symlinker.LinkCall.Stub = func(o string, n string) error {
+					if strings.Contains(o, ".yarnrc") {
+						return errors.New("symlinking .yarnrc error")
+					}
+					return nil
+				}
+			})
+
+			it("errors", func() {
+				_, err := build(packit.BuildContext{
+					WorkingDir: workingDir,
+					CNBPath:    cnbDir,
+					Layers:     packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{Name: "node_modules"},
+						},
+					},
+				})
+				Expect(err).To(MatchError(ContainSubstring("symlinking .yarnrc error")))
+			})
+		})
+
+		context("during the build installation process", func() {
+			it.Before(func() {
+				entryResolver.MergeLayerTypesCall.Returns.Build = true
+			})
+			context("when the layer cannot be retrieved", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(layersDir, "build-modules.toml"), nil, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(packit.BuildContext{
 						WorkingDir: workingDir,
 						CNBPath:    cnbDir,
 						Layers:     packit.Layers{Path: layersDir},
@@ -1224,3 +2801,79 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 }
+
+// writeNativeAddon writes a minimal ELF shared object with the given needed
+// libraries, runpath and dynamic symbols to path.
+func writeNativeAddon(t *testing.T, path string, machine elf.Machine, needed []string, runpath string, symbols []string) {
+	t.Helper()
+
+	dynstr := []byte{0}
+	addString := func(s string) uint32 {
+		offset := uint32(len(dynstr))
+		dynstr = append(dynstr, append([]byte(s), 0)...)
+		return offset
+	}
+
+	dynsym := bytes.NewBuffer(nil)
+	Expect := NewWithT(t).Expect
+	Expect(binary.Write(dynsym, binary.LittleEndian, elf.Sym64{})).To(Succeed())
+	for _, symbol := range symbols {
+		Expect(binary.Write(dynsym, binary.LittleEndian, elf.Sym64{
+			Name:  addString(symbol),
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+			Shndx: 1,
+		})).To(Succeed())
+	}
+
+	dynamic := bytes.NewBuffer(nil)
+	for _, library := range needed {
+		Expect(binary.Write(dynamic, binary.LittleEndian, elf.Dyn64{Tag: int64(elf.DT_NEEDED), Val: uint64(addString(library))})).To(Succeed())
+	}
+	if runpath != "" {
+		Expect(binary.Write(dynamic, binary.LittleEndian, elf.Dyn64{Tag: int64(elf.DT_RUNPATH), Val: uint64(addString(runpath))})).To(Succeed())
+	}
+	Expect(binary.Write(dynamic, binary.LittleEndian, elf.Dyn64{Tag: int64(elf.DT_NULL)})).To(Succeed())
+
+	shstrtab := []byte("\x00.dynstr\x00.dynsym\x00.dynamic\x00.shstrtab\x00")
+
+	headerSize := uint64(binary.Size(elf.Header64{}))
+	dynstrOffset := headerSize
+	dynsymOffset := dynstrOffset + uint64(len(dynstr))
+	dynamicOffset := dynsymOffset + uint64(dynsym.Len())
+	shstrtabOffset := dynamicOffset + uint64(dynamic.Len())
+	sectionsOffset := shstrtabOffset + uint64(len(shstrtab))
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: dynstrOffset, Size: uint64(len(dynstr))},
+		{Name: 9, Type: uint32(elf.SHT_DYNSYM), Off: dynsymOffset, Size: uint64(dynsym.Len()), Link: 1, Info: 1, Entsize: 24},
+		{Name: 17, Type: uint32(elf.SHT_DYNAMIC), Off: dynamicOffset, Size: uint64(dynamic.Len()), Link: 1, Entsize: 16},
+		{Name: 26, Type: uint32(elf.SHT_STRTAB), Off: shstrtabOffset, Size: uint64(len(shstrtab))},
+	}
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionsOffset,
+		Ehsize:    uint16(headerSize),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  4,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	content := bytes.NewBuffer(nil)
+	Expect(binary.Write(content, binary.LittleEndian, header)).To(Succeed())
+	content.Write(dynstr)
+	content.Write(dynsym.Bytes())
+	content.Write(dynamic.Bytes())
+	content.Write(shstrtab)
+	Expect(binary.Write(content, binary.LittleEndian, sections)).To(Succeed())
+
+	Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+	Expect(os.WriteFile(path, content.Bytes(), 0600)).To(Succeed())
+}
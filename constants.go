@@ -4,4 +4,5 @@ const (
 	PlanDependencyNodeModules = "node_modules"
 	PlanDependencyNode        = "node"
 	PlanDependencyYarn        = "yarn"
+	PlanDependencyCPython     = "cpython"
 )
@@ -57,21 +57,44 @@ func Detect() packit.DetectFunc {
 			}
 		}
 
-		return packit.DetectResult{
-			Plan: packit.BuildPlan{
-				Provides: []packit.BuildPlanProvision{
-					{Name: PlanDependencyNodeModules},
-				},
-				Requires: []packit.BuildPlanRequirement{
-					nodeRequirement,
-					{
-						Name: PlanDependencyYarn,
-						Metadata: BuildPlanMetadata{
-							Build: true,
-						},
+		plan := packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: PlanDependencyNodeModules},
+			},
+			Requires: []packit.BuildPlanRequirement{
+				nodeRequirement,
+				{
+					Name: PlanDependencyYarn,
+					Metadata: BuildPlanMetadata{
+						Build: true,
 					},
 				},
 			},
+		}
+
+		requiredNodeGypPackages, optionalNodeGypPackages, err := findNodeGypPackages(projectPath)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		// Packages compiled with node-gyp need python, so it is requested
+		// whenever a buildpack in the group provides it. The alternative plan
+		// without it keeps detection passing otherwise, and the build checks
+		// that the tools are available before installing.
+		if len(requiredNodeGypPackages) > 0 || len(optionalNodeGypPackages) > 0 {
+			withPython := plan
+			withPython.Requires = append(append([]packit.BuildPlanRequirement{}, plan.Requires...), packit.BuildPlanRequirement{
+				Name: PlanDependencyCPython,
+				Metadata: BuildPlanMetadata{
+					Build: true,
+				},
+			})
+			withPython.Or = []packit.BuildPlan{plan}
+			plan = withPython
+		}
+
+		return packit.DetectResult{
+			Plan: plan,
 		}, nil
 	}
 }
@@ -104,6 +104,73 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when packages compile native addons with node-gyp", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "custom", "yarn.lock"), []byte(`# yarn lockfile v1
+
+
+bcrypt@^5.0.0:
+  version "5.1.1"
+  dependencies:
+    "@mapbox/node-pre-gyp" "^1.0.11"
+    node-addon-api "^5.0.0"
+
+microtime@^3.0.0:
+  version "3.1.1"
+  dependencies:
+    node-addon-api "^5.0.0"
+`), 0644)).To(Succeed())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "custom", "node_modules", "vendored-addon"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "custom", "node_modules", "vendored-addon", "package.json"), []byte(`{"name": "vendored-addon", "version": "1.0.0"}`), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "custom", "node_modules", "vendored-addon", "binding.gyp"), []byte(`{}`), 0644)).To(Succeed())
+		})
+
+		it("optionally requires python", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			requires := []packit.BuildPlanRequirement{
+				{
+					Name: "node",
+					Metadata: yarninstall.BuildPlanMetadata{
+						Version:       "some-version",
+						VersionSource: "package.json",
+						Build:         true,
+					},
+				},
+				{
+					Name: "yarn",
+					Metadata: yarninstall.BuildPlanMetadata{
+						Build: true,
+					},
+				},
+			}
+
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "node_modules"},
+				},
+				Requires: append(requires, packit.BuildPlanRequirement{
+					Name: "cpython",
+					Metadata: yarninstall.BuildPlanMetadata{
+						Build: true,
+					},
+				}),
+				Or: []packit.BuildPlan{
+					{
+						Provides: []packit.BuildPlanProvision{
+							{Name: "node_modules"},
+						},
+						Requires: requires,
+					},
+				},
+			}))
+		})
+	})
+
 	context("when there is no yarn.lock file", func() {
 		it.Before(func() {
 			Expect(os.Remove(filepath.Join(workingDir, "custom", "yarn.lock"))).To(Succeed())
@@ -9,12 +9,15 @@ type InstallProcess struct {
 		Receives  struct {
 			WorkingDir       string
 			ModulesLayerPath string
+			CacheLayerPath   string
+			PlatformDir      string
 			Launch           bool
 		}
 		Returns struct {
-			Error error
+			StringSlice []string
+			Error       error
 		}
-		Stub func(string, string, bool) error
+		Stub func(string, string, string, string, bool) ([]string, error)
 	}
 	SetupModulesCall struct {
 		mutex     sync.Mutex
@@ -46,19 +49,33 @@ type InstallProcess struct {
 		Stub func(string, map[string]interface {
 		}) (bool, string, error)
 	}
+	VersionCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir string
+		}
+		Returns struct {
+			String string
+			Error  error
+		}
+		Stub func(string) (string, error)
+	}
 }
 
-func (f *InstallProcess) Execute(param1 string, param2 string, param3 bool) error {
+func (f *InstallProcess) Execute(param1 string, param2 string, param3 string, param4 string, param5 bool) ([]string, error) {
 	f.ExecuteCall.mutex.Lock()
 	defer f.ExecuteCall.mutex.Unlock()
 	f.ExecuteCall.CallCount++
 	f.ExecuteCall.Receives.WorkingDir = param1
 	f.ExecuteCall.Receives.ModulesLayerPath = param2
-	f.ExecuteCall.Receives.Launch = param3
+	f.ExecuteCall.Receives.CacheLayerPath = param3
+	f.ExecuteCall.Receives.PlatformDir = param4
+	f.ExecuteCall.Receives.Launch = param5
 	if f.ExecuteCall.Stub != nil {
-		return f.ExecuteCall.Stub(param1, param2, param3)
+		return f.ExecuteCall.Stub(param1, param2, param3, param4, param5)
 	}
-	return f.ExecuteCall.Returns.Error
+	return f.ExecuteCall.Returns.StringSlice, f.ExecuteCall.Returns.Error
 }
 func (f *InstallProcess) SetupModules(param1 string, param2 string, param3 string) (string, error) {
 	f.SetupModulesCall.mutex.Lock()
@@ -84,3 +101,13 @@ func (f *InstallProcess) ShouldRun(param1 string, param2 map[string]interface {
 	}
 	return f.ShouldRunCall.Returns.Run, f.ShouldRunCall.Returns.Sha, f.ShouldRunCall.Returns.Err
 }
+func (f *InstallProcess) Version(param1 string) (string, error) {
+	f.VersionCall.mutex.Lock()
+	defer f.VersionCall.mutex.Unlock()
+	f.VersionCall.CallCount++
+	f.VersionCall.Receives.WorkingDir = param1
+	if f.VersionCall.Stub != nil {
+		return f.VersionCall.Stub(param1)
+	}
+	return f.VersionCall.Returns.String, f.VersionCall.Returns.Error
+}
@@ -0,0 +1,59 @@
+package fakes
+
+import "sync"
+
+type RebuildProcess struct {
+	NodeABICall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir string
+		}
+		Returns struct {
+			String string
+			Error  error
+		}
+		Stub func(string) (string, error)
+	}
+	RebuildCall struct {
+		mutex     sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir       string
+			ModulesLayerPath string
+			CacheLayerPath   string
+			PlatformDir      string
+			Packages         []string
+		}
+		Returns struct {
+			StringSlice []string
+			Error       error
+		}
+		Stub func(string, string, string, string, []string) ([]string, error)
+	}
+}
+
+func (f *RebuildProcess) NodeABI(param1 string) (string, error) {
+	f.NodeABICall.mutex.Lock()
+	defer f.NodeABICall.mutex.Unlock()
+	f.NodeABICall.CallCount++
+	f.NodeABICall.Receives.WorkingDir = param1
+	if f.NodeABICall.Stub != nil {
+		return f.NodeABICall.Stub(param1)
+	}
+	return f.NodeABICall.Returns.String, f.NodeABICall.Returns.Error
+}
+func (f *RebuildProcess) Rebuild(param1 string, param2 string, param3 string, param4 string, param5 []string) ([]string, error) {
+	f.RebuildCall.mutex.Lock()
+	defer f.RebuildCall.mutex.Unlock()
+	f.RebuildCall.CallCount++
+	f.RebuildCall.Receives.WorkingDir = param1
+	f.RebuildCall.Receives.ModulesLayerPath = param2
+	f.RebuildCall.Receives.CacheLayerPath = param3
+	f.RebuildCall.Receives.PlatformDir = param4
+	f.RebuildCall.Receives.Packages = param5
+	if f.RebuildCall.Stub != nil {
+		return f.RebuildCall.Stub(param1, param2, param3, param4, param5)
+	}
+	return f.RebuildCall.Returns.StringSlice, f.RebuildCall.Returns.Error
+}
@@ -0,0 +1,174 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitDependencies returns the lockfile entries that yarn fetches by cloning a
+// git repository, split by whether they are cloned over SSH or HTTPS. Entries
+// that resolve to a tarball (e.g. codeload.github.com) do not require git.
+func gitDependencies(entries []LockfileEntry) (ssh, https []LockfileEntry) {
+	for _, entry := range entries {
+		source := entry.Resolved
+		if source == "" {
+			source = specifierRange(entry.Specifiers[0])
+		}
+
+		switch {
+		case strings.HasPrefix(source, "git+ssh://"),
+			strings.HasPrefix(source, "ssh://"),
+			strings.HasPrefix(source, "git@"):
+			ssh = append(ssh, entry)
+
+		case strings.HasPrefix(source, "git+https://"),
+			strings.HasPrefix(source, "git+http://"),
+			strings.HasPrefix(source, "git://"),
+			strings.HasPrefix(source, "github:"),
+			strings.HasPrefix(source, "gitlab:"),
+			strings.HasPrefix(source, "bitbucket:"):
+			https = append(https, entry)
+		}
+	}
+
+	return ssh, https
+}
+
+// configureGit inspects the yarn.lock for git-hosted dependencies and, when
+// there are any, makes the credentials from the 'git-credentials' and 'ssh'
+// bindings available to git. The returned environment only applies to the
+// yarn invocations of this install and the cleanup function removes any
+// temporary files that were created.
+func (ip YarnInstallProcess) configureGit(workingDir, platformDir string) ([]string, func(), error) {
+	cleanup := func() {}
+
+	entries, err := ParseYarnLock(filepath.Join(workingDir, "yarn.lock"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, cleanup, nil
+		}
+		return nil, cleanup, err
+	}
+
+	sshDeps, httpsDeps := gitDependencies(entries)
+	if len(sshDeps)+len(httpsDeps) == 0 {
+		return nil, cleanup, nil
+	}
+
+	ip.logger.Subprocess("Found git-hosted dependencies:")
+	for _, name := range lockfileEntryNames(append(sshDeps, httpsDeps...)) {
+		ip.logger.Action(name)
+	}
+	ip.logger.Break()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, cleanup, fmt.Errorf("failed: yarn.lock contains git-hosted dependencies but git is not available in the build environment:\n%s",
+			formatPackageList(lockfileEntryNames(append(sshDeps, httpsDeps...))))
+	}
+
+	credentialsBinding, err := resolveOptionalBinding(ip.bindingResolver, "git-credentials", platformDir)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	sshBinding, err := resolveOptionalBinding(ip.bindingResolver, "ssh", platformDir)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	if len(sshDeps) > 0 && sshBinding == nil {
+		return nil, cleanup, fmt.Errorf("failed: the following dependencies are fetched over SSH but no binding of type 'ssh' was provided:\n%s",
+			formatPackageList(lockfileEntryNames(sshDeps)))
+	}
+
+	if len(httpsDeps) > 0 && credentialsBinding == nil {
+		ip.logger.Subprocess("No binding of type 'git-credentials' was provided, fetching git-hosted dependencies without credentials")
+		ip.logger.Break()
+	}
+
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+
+	if credentialsBinding != nil {
+		ip.logger.Process("Loading service binding of type 'git-credentials'")
+
+		var path string
+		for _, entry := range []string{"credentials", ".git-credentials"} {
+			if _, ok := credentialsBinding.Entries[entry]; ok {
+				path = filepath.Join(credentialsBinding.Path, entry)
+				break
+			}
+		}
+
+		if path == "" {
+			return nil, cleanup, fmt.Errorf("failed: binding of type 'git-credentials' does not contain required entry 'credentials'")
+		}
+
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=credential.helper",
+			fmt.Sprintf("GIT_CONFIG_VALUE_0=store --file=%s", path),
+		)
+	}
+
+	if sshBinding != nil {
+		ip.logger.Process("Loading service binding of type 'ssh'")
+
+		if _, ok := sshBinding.Entries["ssh-privatekey"]; !ok {
+			return nil, cleanup, fmt.Errorf("failed: binding of type 'ssh' does not contain required entry 'ssh-privatekey'")
+		}
+
+		if _, ok := sshBinding.Entries["known_hosts"]; !ok {
+			return nil, cleanup, fmt.Errorf("failed: binding of type 'ssh' does not contain required entry 'known_hosts', add the host keys of the git servers (e.g. from ssh-keyscan) so that they can be verified")
+		}
+
+		// ssh refuses to use a private key that is readable by other users, so
+		// the key is copied into a temporary file with restricted permissions.
+		sshDir, err := os.MkdirTemp("", "yarn-install-ssh")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to create temporary ssh directory: %w", err)
+		}
+		cleanup = func() { _ = os.RemoveAll(sshDir) }
+
+		keyPath := filepath.Join(sshDir, "id")
+		err = copyFileWithMode(filepath.Join(sshBinding.Path, "ssh-privatekey"), keyPath, 0600)
+		if err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("failed to copy ssh private key: %w", err)
+		}
+
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=%s", keyPath, filepath.Join(sshBinding.Path, "known_hosts")))
+	}
+
+	return env, cleanup, nil
+}
+
+func copyFileWithMode(source, destination string, mode os.FileMode) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func formatPackageList(names []string) string {
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  - %s", name))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,133 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitHookInstallers are tools that install git hooks from a lifecycle script
+// of the app, which fails in the build container as it has no .git
+// directory, with the variables that disable them.
+var gitHookInstallers = []struct {
+	name string
+	env  []string
+}{
+	{name: "husky", env: []string{"HUSKY=0", "HUSKY_SKIP_INSTALL=1"}},
+	{name: "simple-git-hooks", env: []string{"SKIP_INSTALL_SIMPLE_GIT_HOOKS=1"}},
+	{name: "lefthook", env: []string{"LEFTHOOK=0"}},
+}
+
+// rootLifecycleScripts are the lifecycle scripts of the app that yarn install
+// runs.
+var rootLifecycleScripts = []string{"preinstall", "install", "postinstall", "prepublish", "prepare"}
+
+// gitHooksEnvironment returns the environment that disables the known git
+// hook installers during yarn install, unless BP_YARN_DISABLE_GIT_HOOKS is
+// false. Variables that are already set in the environment take precedence.
+func (ip YarnInstallProcess) gitHooksEnvironment() ([]string, error) {
+	disable, err := parseBoolEnvDefault("BP_YARN_DISABLE_GIT_HOOKS", true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !disable {
+		return nil, nil
+	}
+
+	var env []string
+	for _, installer := range gitHookInstallers {
+		for _, variable := range installer.env {
+			name, _, _ := strings.Cut(variable, "=")
+			if _, ok := os.LookupEnv(name); !ok {
+				env = append(env, variable)
+			}
+		}
+	}
+
+	return env, nil
+}
+
+// LifecycleScriptError is returned when yarn install fails while running a
+// lifecycle script of the app itself rather than of a dependency.
+type LifecycleScriptError struct {
+	Script      string
+	Command     string
+	Remediation string
+	Err         error
+}
+
+func (e LifecycleScriptError) Error() string {
+	return fmt.Sprintf("failed to execute yarn install: the %s script of the app (%q) failed: %s\n%s", e.Script, e.Command, e.Err, e.Remediation)
+}
+
+func (e LifecycleScriptError) Unwrap() error {
+	return e.Err
+}
+
+// detectLifecycleScriptFailure returns a LifecycleScriptError when the last
+// script yarn started before failing, printed as "$ <command>", is one of the
+// lifecycle scripts of the app in workingDir.
+func detectLifecycleScriptFailure(workingDir, output string, err error) error {
+	content, readErr := os.ReadFile(filepath.Join(workingDir, "package.json"))
+	if readErr != nil {
+		return nil
+	}
+
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(content, &pkg) != nil {
+		return nil
+	}
+
+	var command string
+	for _, line := range strings.Split(output, "\n") {
+		if c, ok := strings.CutPrefix(strings.TrimSpace(line), "$ "); ok {
+			command = strings.TrimSpace(c)
+		}
+	}
+
+	if command == "" {
+		return nil
+	}
+
+	for _, script := range rootLifecycleScripts {
+		if strings.TrimSpace(pkg.Scripts[script]) != command {
+			continue
+		}
+
+		return LifecycleScriptError{
+			Script:      script,
+			Command:     command,
+			Remediation: lifecycleScriptRemediation(script, command, output),
+			Err:         err,
+		}
+	}
+
+	return nil
+}
+
+func lifecycleScriptRemediation(script, command, output string) string {
+	for _, installer := range gitHookInstallers {
+		if !strings.Contains(command, installer.name) {
+			continue
+		}
+
+		return fmt.Sprintf("The %s script installs git hooks with %s, which needs a .git directory that is not part of the build. "+
+			"%s is disabled with %s during the install unless BP_YARN_DISABLE_GIT_HOOKS=false, so this version of %s or the script itself does not honor it. "+
+			"Change the %s script to skip installing hooks outside of a git repository (for example %q), "+
+			"or set BP_YARN_IGNORE_SCRIPTS=true to skip lifecycle scripts.",
+			script, installer.name, installer.name, strings.Join(installer.env, " and "), installer.name, script, command+" || true")
+	}
+
+	if strings.Contains(output, "not a git repository") || strings.Contains(output, ".git can't be found") {
+		return fmt.Sprintf("The %s script needs a .git directory, which is not part of the build. "+
+			"Change it to tolerate running outside of a git repository, or set BP_YARN_IGNORE_SCRIPTS=true to skip lifecycle scripts.", script)
+	}
+
+	return fmt.Sprintf("Lifecycle scripts of the app run during yarn install in the build container, which has no .git directory and only the production dependencies for the launch install. "+
+		"Make the %s script tolerate the build environment, or set BP_YARN_IGNORE_SCRIPTS=true to skip lifecycle scripts.", script)
+}
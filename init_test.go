@@ -13,7 +13,11 @@ func TestUnitYarn(t *testing.T) {
 	suite("CacheHandler", testCacheHandler)
 	suite("Detect", testDetect)
 	suite("InstallProcess", testInstallProcess)
+	suite("LockfileSBOMGenerator", testLockfileSBOMGenerator)
 	suite("PackageManagerConfigurationManager", testPackageManagerConfigurationManager)
+	suite("RebuildProcess", testRebuildProcess)
+	suite("Redactor", testRedactor)
 	suite("Symlinker", testSymlinker)
+	suite("YarnLock", testYarnLock)
 	suite.Run(t)
 }
@@ -0,0 +1,144 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The kinds of yarn install failures that are recognized in its output. An
+// InstallError matches its kind with errors.Is.
+var (
+	ErrLockfileOutdated  = errors.New("yarn.lock is out of date")
+	ErrRegistryAuth      = errors.New("registry authentication failed")
+	ErrNetwork           = errors.New("registry could not be reached")
+	ErrIntegrityMismatch = errors.New("package integrity check failed")
+	ErrNativeBuildTools  = errors.New("native build tools are missing")
+	ErrNodeABIMismatch   = errors.New("native addon was built for another Node.js version")
+	ErrOutOfDisk         = errors.New("out of disk space")
+)
+
+// InstallError is a yarn install failure that was recognized in its output,
+// with an explanation and the steps that usually resolve it.
+type InstallError struct {
+	Kind        error
+	Line        string
+	Explanation string
+	NextSteps   []string
+	Err         error
+}
+
+func (e InstallError) Error() string {
+	message := fmt.Sprintf("failed to execute yarn install: %s: %s", e.Kind, e.Err)
+	if e.Line != "" {
+		message = fmt.Sprintf("%s\n  %s", message, e.Line)
+	}
+
+	message = fmt.Sprintf("%s\n\n%s\n\nNext steps:", message, e.Explanation)
+	for _, step := range e.NextSteps {
+		message = fmt.Sprintf("%s\n  - %s", message, step)
+	}
+
+	return message
+}
+
+func (e InstallError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// installFailures are checked in order, so that root causes such as a full
+// disk win over the symptoms they cause.
+var installFailures = []struct {
+	kind        error
+	pattern     *regexp.Regexp
+	explanation string
+	nextSteps   []string
+}{
+	{
+		kind:        ErrOutOfDisk,
+		pattern:     regexp.MustCompile(`ENOSPC|no space left on device`),
+		explanation: "The build container ran out of disk space while installing the dependencies.",
+		nextSteps: []string{
+			"Free up disk space on the machine or increase the disk available to the build",
+			"Reduce the size of the install, for example by setting BP_YARN_SKIP_BINARY_DOWNLOADS or removing unused dependencies",
+		},
+	},
+	{
+		kind:        ErrLockfileOutdated,
+		pattern:     regexp.MustCompile(`Your lockfile needs to be updated`),
+		explanation: "The dependencies in package.json do not match yarn.lock. The buildpack installs with --frozen-lockfile, so yarn.lock is never updated during the build.",
+		nextSteps: []string{
+			"Run 'yarn install' locally and commit the updated yarn.lock",
+			"Make sure that the yarn.lock belongs to the package.json at BP_NODE_PROJECT_PATH",
+		},
+	},
+	{
+		kind:        ErrIntegrityMismatch,
+		pattern:     regexp.MustCompile(`Integrity check failed|Incorrect integrity|Hashes don't match|integrity checksum failed`),
+		explanation: "A downloaded package does not match the integrity checksum recorded in yarn.lock. The registry or a mirror served different contents than when yarn.lock was written.",
+		nextSteps: []string{
+			"Check that the registry, mirror or offline mirror serves the same package tarballs that yarn.lock was created from",
+			"If the package was republished, regenerate its entry in yarn.lock locally and commit it",
+		},
+	},
+	{
+		kind:        ErrRegistryAuth,
+		pattern:     regexp.MustCompile(`\b40[13]\b.*(Unauthorized|Forbidden)|(Unauthorized|Forbidden).*\b40[13]\b|authentication token not provided`),
+		explanation: "The registry rejected the request for a package because the build has no valid credentials for it.",
+		nextSteps: []string{
+			"Provide the registry credentials through a binding of type 'npmrc' or 'yarnrc'",
+			"Check that the token in the binding is valid and grants read access to the package",
+		},
+	},
+	{
+		kind:        ErrNetwork,
+		pattern:     regexp.MustCompile(`ENOTFOUND|ETIMEDOUT|ECONNREFUSED|ECONNRESET|EAI_AGAIN|ESOCKETTIMEDOUT|trouble with your network connection`),
+		explanation: "yarn could not reach the registry to download the packages.",
+		nextSteps: []string{
+			"Check that the build has network access to the registry, or configure an HTTP(S) proxy",
+			"For offline builds, vendor the packages in an offline mirror or set BP_YARN_REGISTRY_MIRROR to a reachable mirror",
+		},
+	},
+	{
+		kind:        ErrNativeBuildTools,
+		pattern:     regexp.MustCompile(`gyp ERR! find Python|Can't find Python executable|(python3?|make|g\+\+|c\+\+|cc): (command )?not found|not found: (make|python3?)`),
+		explanation: "A package compiles a native addon with node-gyp, which needs python, make and a C++ compiler that are not available in the build environment.",
+		nextSteps: []string{
+			"Add a buildpack that provides python, or use a builder whose build image includes the build tools, such as the Paketo Full builder",
+			"Provide the Node.js headers through a binding of type 'node-headers' for offline builds",
+		},
+	},
+	{
+		kind:        ErrNodeABIMismatch,
+		pattern:     regexp.MustCompile(`was compiled against a different Node\.js version|This version of Node\.js requires NODE_MODULE_VERSION`),
+		explanation: "A script loaded a native addon that was built for a different Node.js version (NODE_MODULE_VERSION) than the one used for the build, such as an addon in a vendored node_modules directory.",
+		nextSteps: []string{
+			"Remove the vendored node_modules directory from the app source so that the dependencies are installed and compiled during the build",
+			"Set the Node.js version in the engines field of package.json or with BP_NODE_VERSION to the version the addon was built for",
+		},
+	},
+}
+
+// classifyInstallFailure returns an InstallError when the output of a failed
+// yarn install matches a known failure.
+func classifyInstallFailure(output string, err error) error {
+	lines := strings.Split(output, "\n")
+	for _, failure := range installFailures {
+		for _, line := range lines {
+			if !failure.pattern.MatchString(line) {
+				continue
+			}
+
+			return InstallError{
+				Kind:        failure.kind,
+				Line:        strings.TrimSpace(line),
+				Explanation: failure.explanation,
+				NextSteps:   failure.nextSteps,
+				Err:         err,
+			}
+		}
+	}
+
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2/fs"
@@ -24,16 +27,20 @@ type Executable interface {
 }
 
 type YarnInstallProcess struct {
-	executable Executable
-	summer     Summer
-	logger     scribe.Emitter
+	executable      Executable
+	rebuildProcess  RebuildProcess
+	summer          Summer
+	bindingResolver BindingResolver
+	logger          scribe.Emitter
 }
 
-func NewYarnInstallProcess(executable Executable, summer Summer, logger scribe.Emitter) YarnInstallProcess {
+func NewYarnInstallProcess(executable Executable, rebuildProcess RebuildProcess, summer Summer, bindingResolver BindingResolver, logger scribe.Emitter) YarnInstallProcess {
 	return YarnInstallProcess{
-		executable: executable,
-		summer:     summer,
-		logger:     logger,
+		executable:      executable,
+		rebuildProcess:  rebuildProcess,
+		summer:          summer,
+		bindingResolver: bindingResolver,
+		logger:          logger,
 	}
 }
 
@@ -52,6 +59,11 @@ func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]in
 	ip.logger.Action("yarn.lock -> Found")
 	ip.logger.Break()
 
+	redactor, err := newRedactor(workingDir)
+	if err != nil {
+		return true, "", err
+	}
+
 	buffer := bytes.NewBuffer(nil)
 
 	err = ip.executable.Execute(pexec.Execution{
@@ -61,12 +73,41 @@ func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]in
 		Dir:    workingDir,
 	})
 	if err != nil {
-		return true, "", fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", buffer.String(), err)
+		return true, "", fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", redactor.Redact(buffer.String()), redactor.Redact(err.Error()))
 	}
 
 	nodeEnv := os.Getenv("NODE_ENV")
 	buffer.WriteString(nodeEnv)
 
+	mirrors, err := parseRegistryMirrors()
+	if err != nil {
+		return true, "", err
+	}
+
+	for _, mirror := range mirrors {
+		buffer.WriteString(fmt.Sprintf("\nmirror %s=%s", mirror.Host, mirror.Mirror))
+	}
+
+	ignoreScripts, allowedScripts, err := parseIgnoreScripts()
+	if err != nil {
+		return true, "", err
+	}
+
+	if ignoreScripts {
+		allowedScripts = append([]string{}, allowedScripts...)
+		sort.Strings(allowedScripts)
+		buffer.WriteString(fmt.Sprintf("\nignore-scripts allowed=%s", strings.Join(allowedScripts, ",")))
+	}
+
+	skipBinaryDownloads, err := parseBoolEnvDefault("BP_YARN_SKIP_BINARY_DOWNLOADS", true)
+	if err != nil {
+		return true, "", err
+	}
+
+	if !skipBinaryDownloads {
+		buffer.WriteString("\nkeep-binary-downloads")
+	}
+
 	file, err := os.CreateTemp("", "config-file")
 	if err != nil {
 		return true, "", fmt.Errorf("failed to create temp file for %s: %w", file.Name(), err)
@@ -95,6 +136,24 @@ func (ip YarnInstallProcess) ShouldRun(workingDir string, metadata map[string]in
 	return false, "", nil
 }
 
+// Version returns the version of yarn that is used in the given working
+// directory.
+func (ip YarnInstallProcess) Version(workingDir string) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+
+	err := ip.executable.Execute(pexec.Execution{
+		Args:   []string{"--version"},
+		Stdout: buffer,
+		Stderr: buffer,
+		Dir:    workingDir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute yarn --version:\n%s\nerror: %s", buffer.String(), err)
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
+
 func (ip YarnInstallProcess) SetupModules(workingDir, currentModulesLayerPath, nextModulesLayerPath string) (string, error) {
 	if currentModulesLayerPath != "" {
 		err := fs.Copy(filepath.Join(currentModulesLayerPath, "node_modules"), filepath.Join(nextModulesLayerPath, "node_modules"))
@@ -131,6 +190,11 @@ func (ip YarnInstallProcess) SetupModules(workingDir, currentModulesLayerPath, n
 			return "", fmt.Errorf("failed to move node_modules directory to layer: %w", err)
 		}
 
+		err = ip.removeForeignAddons(filepath.Join(nextModulesLayerPath, "node_modules"))
+		if err != nil {
+			return "", err
+		}
+
 		err = os.Symlink(filepath.Join(nextModulesLayerPath, "node_modules"), filepath.Join(workingDir, "node_modules"))
 		if err != nil {
 			return "", fmt.Errorf("failed to symlink node_modules into working directory: %w", err)
@@ -140,24 +204,121 @@ func (ip YarnInstallProcess) SetupModules(workingDir, currentModulesLayerPath, n
 	return nextModulesLayerPath, nil
 }
 
+// removeForeignAddons removes the vendored packages whose native addons were
+// built for another platform. yarn keeps such packages when the lockfile is
+// satisfied, so they are removed together with the integrity file to make
+// yarn install reinstall and rebuild them.
+func (ip YarnInstallProcess) removeForeignAddons(modulesDir string) error {
+	packages, err := findForeignAddons(modulesDir, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		return nil
+	}
+
+	ip.logger.Subprocess("Rebuilding vendored packages with native addons for another platform:")
+	for _, p := range packages {
+		ip.logger.Action("%s: %s", p.Path, strings.Join(p.Reasons, ", "))
+
+		err = os.RemoveAll(filepath.Join(modulesDir, p.Path))
+		if err != nil {
+			return fmt.Errorf("failed to remove vendored package %s: %w", p.Path, err)
+		}
+	}
+	ip.logger.Break()
+
+	err = os.RemoveAll(filepath.Join(modulesDir, ".yarn-integrity"))
+	if err != nil {
+		return fmt.Errorf("failed to remove yarn integrity file: %w", err)
+	}
+
+	return nil
+}
+
 // The build process here relies on yarn install ... --frozen-lockfile note that
 // even if we provide a node_modules directory we must run a 'yarn install' as
-// this is the ONLY way to rebuild native extensions.
-func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath string, launch bool) error {
+// this is the ONLY way to rebuild native extensions. When
+// BP_YARN_USE_VENDORED_MODULES is set, a vendored node_modules directory that
+// is complete and has no native extensions or install scripts is used as-is.
+// Downloads of native builds are kept in the cache layer at cacheLayerPath,
+// unless it is empty.
+func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, launch bool) (commands []string, err error) {
+	useVendored, err := parseBoolEnv("BP_YARN_USE_VENDORED_MODULES")
+	if err != nil {
+		return nil, err
+	}
+
+	if useVendored {
+		reason, err := checkVendoredModules(workingDir, filepath.Join(modulesLayerPath, "node_modules"), launch)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason == "" {
+			ip.logger.Subprocess("Using vendored node_modules as-is, skipping 'yarn install'")
+			ip.logger.Action("node_modules/.yarn-integrity matches yarn.lock and no package builds native code or runs install scripts")
+			ip.logger.Break()
+			return nil, nil
+		}
+
+		ip.logger.Subprocess("Not using vendored node_modules as-is: %s", reason)
+	}
+
+	proxy, err := resolveProxyConfiguration(ip.bindingResolver, platformDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve proxy configuration: %w", err)
+	}
+
 	environment := os.Environ()
 	environment = append(environment, fmt.Sprintf("PATH=%s%c%s", os.Getenv("PATH"), os.PathListSeparator, filepath.Join("node_modules", ".bin")))
+	environment = append(environment, proxy.Environment()...)
+
+	gitEnvironment, cleanup, err := ip.configureGit(workingDir, platformDir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	environment = append(environment, gitEnvironment...)
+
+	nativeEnvironment, err := nativeBuildEnvironment(ip.bindingResolver, ip.logger, cacheLayerPath, platformDir)
+	if err != nil {
+		return nil, err
+	}
+	environment = append(environment, nativeEnvironment...)
+
+	downloadEnvironment, err := ip.binaryDownloadEnvironment(cacheLayerPath, launch)
+	if err != nil {
+		return nil, err
+	}
+	environment = append(environment, downloadEnvironment...)
+
+	hooksEnvironment, err := ip.gitHooksEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	environment = append(environment, hooksEnvironment...)
+
+	redactor, err := newRedactor(workingDir)
+	if err != nil {
+		return nil, err
+	}
 
 	buffer := bytes.NewBuffer(nil)
 
-	err := ip.executable.Execute(pexec.Execution{
-		Args:   []string{"config", "get", "yarn-offline-mirror"},
+	configArgs := append([]string{"config", "get", "yarn-offline-mirror"}, proxy.Args()...)
+	commands = append(commands, fmt.Sprintf("yarn %s", strings.Join(redactProxyArgs(configArgs), " ")))
+
+	err = ip.executable.Execute(pexec.Execution{
+		Args:   configArgs,
 		Stdout: buffer,
 		Stderr: buffer,
 		Env:    environment,
 		Dir:    workingDir,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", buffer.String(), err)
+		return nil, fmt.Errorf("failed to execute yarn config output:\n%s\nerror: %s", redactor.Redact(buffer.String()), redactor.Redact(err.Error()))
 	}
 
 	installArgs := []string{"install", "--ignore-engines", "--frozen-lockfile"}
@@ -166,6 +327,15 @@ func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath string, launch
 		installArgs = append(installArgs, "--production", "false")
 	}
 
+	ignoreScripts, allowedScripts, err := parseIgnoreScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	if ignoreScripts {
+		installArgs = append(installArgs, "--ignore-scripts")
+	}
+
 	// Parse yarn config get yarn-offline-mirror output
 	// in case there are any warning lines in the output like:
 	// warning You don't appear to have an internet connection.
@@ -178,26 +348,98 @@ func (ip YarnInstallProcess) Execute(workingDir, modulesLayerPath string, launch
 	}
 	info, err := os.Stat(offlineMirrorDir)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("failed to confirm existence of offline mirror directory: %w", err)
+		return nil, fmt.Errorf("failed to confirm existence of offline mirror directory: %w", err)
 	}
 
 	if info != nil && info.IsDir() {
 		installArgs = append(installArgs, "--offline")
 	}
 
+	installArgs = append(installArgs, proxy.Args()...)
 	installArgs = append(installArgs, "--modules-folder", filepath.Join(modulesLayerPath, "node_modules"))
-	ip.logger.Subprocess("Running 'yarn %s'", strings.Join(installArgs, " "))
+	ip.logger.Subprocess("Running 'yarn %s'", strings.Join(redactProxyArgs(installArgs), " "))
+	commands = append(commands, fmt.Sprintf("yarn %s", strings.Join(redactProxyArgs(installArgs), " ")))
+
+	mirrors, err := parseRegistryMirrors()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mirrors) > 0 {
+		ip.logger.Subprocess("Rewriting yarn.lock registry URLs for install:")
+		for _, mirror := range mirrors {
+			ip.logger.Action(mirror.String())
+		}
+
+		var restore func() error
+		restore, err = rewriteLockfileRegistries(filepath.Join(workingDir, "yarn.lock"), mirrors)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if restoreErr := restore(); restoreErr != nil && err == nil {
+				commands, err = nil, restoreErr
+			}
+		}()
+	}
 
+	previousErrorLog, err := statYarnErrorLog(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	output := redactor.Writer(ip.logger.ActionWriter)
+	installOutput := bytes.NewBuffer(nil)
 	err = ip.executable.Execute(pexec.Execution{
 		Args:   installArgs,
 		Env:    environment,
-		Stdout: ip.logger.ActionWriter,
-		Stderr: ip.logger.ActionWriter,
+		Stdout: io.MultiWriter(output, installOutput),
+		Stderr: io.MultiWriter(output, installOutput),
 		Dir:    workingDir,
 	})
+	if flushErr := output.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
 	if err != nil {
-		return fmt.Errorf("failed to execute yarn install: %w", err)
+		ip.logYarnErrorLog(workingDir, previousErrorLog, redactor)
+
+		installLog := redactor.Redact(installOutput.String())
+		if installErr := classifyInstallFailure(installLog, err); installErr != nil {
+			return nil, installErr
+		}
+		if scriptErr := detectLifecycleScriptFailure(workingDir, installLog, err); scriptErr != nil {
+			return nil, scriptErr
+		}
+		return nil, fmt.Errorf("failed to execute yarn install: %w", err)
 	}
 
-	return nil
+	if ignoreScripts && len(allowedScripts) > 0 {
+		scriptCommands, err := ip.runAllowedScripts(workingDir, modulesLayerPath, cacheLayerPath, platformDir, allowedScripts, environment, redactor)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, scriptCommands...)
+	}
+
+	return commands, nil
+}
+
+// newRedactor returns a Redactor for the credentials configured in the global
+// (possibly bound) and project level .npmrc and .yarnrc files.
+func newRedactor(workingDir string) (Redactor, error) {
+	paths := []string{
+		filepath.Join(workingDir, ".npmrc"),
+		filepath.Join(workingDir, ".yarnrc"),
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, ".npmrc"), filepath.Join(homeDir, ".yarnrc"))
+	}
+
+	redactor, err := NewRedactor(paths...)
+	if err != nil {
+		return Redactor{}, fmt.Errorf("failed to load secrets for redaction: %w", err)
+	}
+
+	return redactor, nil
 }
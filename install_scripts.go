@@ -0,0 +1,234 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// parseIgnoreScripts reads BP_YARN_IGNORE_SCRIPTS, which installs the
+// dependencies without running their lifecycle scripts, and
+// BP_YARN_ALLOWED_SCRIPTS, the comma-separated names of the packages whose
+// install scripts still run.
+func parseIgnoreScripts() (bool, []string, error) {
+	ignore, err := parseBoolEnv("BP_YARN_IGNORE_SCRIPTS")
+	if err != nil {
+		return false, nil, err
+	}
+
+	var allowed []string
+	for _, name := range strings.Split(os.Getenv("BP_YARN_ALLOWED_SCRIPTS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed = append(allowed, name)
+		}
+	}
+
+	return ignore, allowed, nil
+}
+
+// scriptPackage is an installed copy of a package whose install scripts are
+// allowed to run. Like yarn and npm, a package with a binding.gyp and neither
+// an install nor a preinstall script gets an implicit install script that runs
+// node-gyp rebuild, which is left to npm rebuild because node-gyp is only
+// bundled with npm and not on the PATH.
+type scriptPackage struct {
+	installedPackage
+	scripts  []string
+	buildGyp bool
+}
+
+// allowedScriptPackages returns the installed copies of the allowed packages
+// that have install scripts, ordered so that every package comes after the
+// allowed packages it depends on, directly or through other packages.
+func allowedScriptPackages(modulesDir string, allowed []string) ([]scriptPackage, error) {
+	installed, err := findInstalledPackages(modulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies := map[string][]string{}
+	for _, p := range installed {
+		for _, deps := range []map[string]string{p.Dependencies, p.OptionalDependencies} {
+			for name := range deps {
+				dependencies[p.Name] = append(dependencies[p.Name], name)
+			}
+		}
+	}
+
+	isAllowed := map[string]bool{}
+	for _, name := range allowed {
+		isAllowed[name] = true
+	}
+
+	// reaches reports whether the package depends on target, directly or
+	// transitively.
+	reaches := func(from, target string) bool {
+		visited := map[string]bool{}
+		stack := append([]string{}, dependencies[from]...)
+		for len(stack) > 0 {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if name == target {
+				return true
+			}
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			stack = append(stack, dependencies[name]...)
+		}
+		return false
+	}
+
+	var names []string
+	for name := range isAllowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []string
+	placed := map[string]bool{}
+	var place func(name string, path []string)
+	place = func(name string, path []string) {
+		if placed[name] {
+			return
+		}
+		for _, seen := range path {
+			if seen == name {
+				return
+			}
+		}
+		for _, other := range names {
+			if other != name && reaches(name, other) {
+				place(other, append(path, name))
+			}
+		}
+		if !placed[name] {
+			placed[name] = true
+			ordered = append(ordered, name)
+		}
+	}
+	for _, name := range names {
+		place(name, nil)
+	}
+
+	rank := map[string]int{}
+	for i, name := range ordered {
+		rank[name] = i
+	}
+
+	var packages []scriptPackage
+	for _, p := range installed {
+		if !isAllowed[p.Name] {
+			continue
+		}
+
+		var buildGyp bool
+		if p.Scripts["install"] == "" && p.Scripts["preinstall"] == "" {
+			_, err := os.Stat(filepath.Join(filepath.Dir(p.path), "binding.gyp"))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to stat binding.gyp: %w", err)
+			}
+			buildGyp = err == nil
+		}
+
+		var scripts []string
+		for _, script := range installScripts {
+			if p.Scripts[script] != "" || (script == "install" && buildGyp) {
+				scripts = append(scripts, script)
+			}
+		}
+
+		packages = append(packages, scriptPackage{installedPackage: p, scripts: scripts, buildGyp: buildGyp})
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		if rank[packages[i].Name] != rank[packages[j].Name] {
+			return rank[packages[i].Name] < rank[packages[j].Name]
+		}
+		return packages[i].path < packages[j].path
+	})
+
+	return packages, nil
+}
+
+// runAllowedScripts runs the install scripts of the allowed packages in the
+// node_modules directory of the modules layer after an install with
+// --ignore-scripts and returns the commands it ran.
+func (ip YarnInstallProcess) runAllowedScripts(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, allowed []string, environment []string, redactor Redactor) ([]string, error) {
+	modulesDir := filepath.Join(modulesLayerPath, "node_modules")
+	packages, err := allowedScriptPackages(modulesDir, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(packages) == 0 {
+		ip.logger.Subprocess("None of the allowed packages are installed")
+		ip.logger.Break()
+		return nil, nil
+	}
+
+	environment = append(environment, fmt.Sprintf("PATH=%s%c%s", os.Getenv("PATH"), os.PathListSeparator, filepath.Join(modulesDir, ".bin")))
+
+	ip.logger.Subprocess("Running install scripts of allowed packages:")
+
+	var commands []string
+	rebuilt := map[string]bool{}
+	for _, p := range packages {
+		packageDir := filepath.Dir(p.path)
+		rel, err := filepath.Rel(modulesDir, packageDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(p.scripts) == 0 {
+			ip.logger.Action("%s@%s (%s): no install scripts", p.Name, p.Version, rel)
+			continue
+		}
+
+		// npm rebuild runs the preinstall, install and postinstall scripts of
+		// every installed copy of the package, so it runs once per name.
+		if p.buildGyp {
+			ip.logger.Action("%s@%s (%s): %s (npm rebuild)", p.Name, p.Version, rel, strings.Join(p.scripts, ", "))
+			if rebuilt[p.Name] {
+				continue
+			}
+			rebuilt[p.Name] = true
+
+			rebuildCommands, err := ip.rebuildProcess.Rebuild(workingDir, modulesLayerPath, cacheLayerPath, platformDir, []string{p.Name})
+			if err != nil {
+				return nil, fmt.Errorf("failed to run install scripts of %s@%s: %w", p.Name, p.Version, err)
+			}
+			commands = append(commands, rebuildCommands...)
+			continue
+		}
+
+		for _, script := range p.scripts {
+			ip.logger.Action("%s@%s (%s): %s", p.Name, p.Version, rel, script)
+			commands = append(commands, fmt.Sprintf("yarn run %s (%s)", script, rel))
+
+			output := redactor.Writer(ip.logger.ActionWriter)
+			err = ip.executable.Execute(pexec.Execution{
+				Args:   []string{"run", script},
+				Env:    environment,
+				Stdout: output,
+				Stderr: output,
+				Dir:    packageDir,
+			})
+			if flushErr := output.Flush(); flushErr != nil && err == nil {
+				err = flushErr
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to run %s script of %s@%s: %w", script, p.Name, p.Version, err)
+			}
+		}
+	}
+	ip.logger.Break()
+
+	return commands, nil
+}
@@ -0,0 +1,133 @@
+package yarninstall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const labelPrefix = "io.paketo.yarn-install"
+
+// launchLabels summarizes the packages installed into the launch layer as
+// image labels so that they can be inspected without extracting the SBOM.
+func launchLabels(projectPath, layerPath, yarnVersion string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	digest, err := fileDigest(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if digest != "" {
+		labels[labelPrefix+".lockfile-digest"] = digest
+	}
+
+	summary, err := summarizeModules(filepath.Join(layerPath, "node_modules"))
+	if err != nil {
+		return nil, err
+	}
+
+	labels[labelPrefix+".production-packages"] = strconv.Itoa(summary.Packages)
+	labels[labelPrefix+".node-modules-size"] = strconv.FormatInt(summary.Size, 10)
+	labels[labelPrefix+".native-addons"] = strconv.FormatBool(summary.NativeAddons)
+
+	if yarnVersion != "" {
+		labels[labelPrefix+".yarn-version"] = yarnVersion
+	}
+
+	return labels, nil
+}
+
+// labelsFromMetadata restores the labels that were recorded in the metadata
+// of a reused layer.
+func labelsFromMetadata(metadata map[string]interface{}) map[string]string {
+	stored, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for key, value := range stored {
+		if s, ok := value.(string); ok {
+			labels[key] = s
+		}
+	}
+
+	return labels
+}
+
+type modulesSummary struct {
+	Packages     int
+	Size         int64
+	NativeAddons bool
+}
+
+// summarizeModules counts the distinct packages installed in a node_modules
+// directory, measures its size and reports whether it contains native addons.
+func summarizeModules(modulesDir string) (modulesSummary, error) {
+	installed, err := findInstalledPackages(modulesDir)
+	if err != nil {
+		return modulesSummary{}, err
+	}
+
+	packages := map[string]bool{}
+	for _, p := range installed {
+		packages[fmt.Sprintf("%s@%s", p.Name, p.Version)] = true
+	}
+
+	summary := modulesSummary{
+		Packages: len(packages),
+	}
+
+	err = filepath.WalkDir(modulesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		if strings.HasSuffix(entry.Name(), ".node") {
+			summary.NativeAddons = true
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		summary.Size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return modulesSummary{}, fmt.Errorf("failed to measure %s: %w", modulesDir, err)
+	}
+
+	return summary, nil
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute digest of %s: %w", path, err)
+	}
+
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(hash.Sum(nil))), nil
+}
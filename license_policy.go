@@ -0,0 +1,312 @@
+package yarninstall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// LicensePolicy lists the SPDX license identifiers that the packages shipped
+// in the launch layer may (allow) or may not (deny) be licensed under. Both
+// lists accept glob patterns such as "BSD-*" and are matched
+// case-insensitively. When the allow list is empty every license that is not
+// denied is allowed.
+type LicensePolicy struct {
+	Allow []string `toml:"allow"`
+	Deny  []string `toml:"deny"`
+}
+
+type licenseViolation struct {
+	Name    string
+	Version string
+	License string
+	Reason  string
+}
+
+// loadLicensePolicy reads the license policy from the file named by
+// BP_YARN_LICENSE_POLICY, relative to the working directory, or otherwise from
+// the policy.toml entry of a license-policy binding. A nil policy is returned
+// when neither is provided.
+func loadLicensePolicy(configurationManager ConfigurationManager, workingDir, platformDir string) (*LicensePolicy, error) {
+	policyPath, ok := os.LookupEnv("BP_YARN_LICENSE_POLICY")
+	if ok && policyPath != "" {
+		if !filepath.IsAbs(policyPath) {
+			policyPath = filepath.Join(workingDir, policyPath)
+		}
+	} else {
+		var err error
+		policyPath, err = configurationManager.DeterminePath("license-policy", platformDir, "policy.toml")
+		if err != nil {
+			return nil, err
+		}
+
+		if policyPath == "" {
+			return nil, nil
+		}
+	}
+
+	var policy LicensePolicy
+	_, err := toml.DecodeFile(policyPath, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse license policy %s: %w", policyPath, err)
+	}
+
+	for _, pattern := range append(append([]string{}, policy.Allow...), policy.Deny...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse license policy %s: malformed pattern %q: %w", policyPath, pattern, err)
+		}
+	}
+
+	return &policy, nil
+}
+
+// checkLicensePolicy evaluates the policy against every package installed in
+// the given node_modules directory, printing a table of the violations and
+// returning an error when there are any.
+func checkLicensePolicy(policy LicensePolicy, modulesDir string, logger scribe.Emitter) error {
+	logger.Process("Checking installed packages against the license policy")
+
+	installed, err := findInstalledPackages(modulesDir)
+	if err != nil {
+		return err
+	}
+
+	violations := policy.evaluate(installed)
+	if len(violations) == 0 {
+		logger.Subprocess("All %d packages comply with the license policy", len(installed))
+		logger.Break()
+		return nil
+	}
+
+	logger.Subprocess("Found %d license policy violations:", len(violations))
+	writer := tabwriter.NewWriter(logger.ActionWriter, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PACKAGE\tVERSION\tLICENSE\tREASON")
+	for _, v := range violations {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", v.Name, v.Version, v.License, v.Reason)
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	logger.Break()
+
+	return fmt.Errorf("failed license policy check: %d packages have forbidden or missing licenses", len(violations))
+}
+
+func (p LicensePolicy) evaluate(packages []installedPackage) []licenseViolation {
+	seen := map[string]bool{}
+	var violations []licenseViolation
+	for _, pkg := range packages {
+		id := fmt.Sprintf("%s@%s", pkg.Name, pkg.Version)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		// The deprecated "licenses" array lists alternative licenses.
+		expression := strings.Join(pkg.licenses(), " OR ")
+		if expression == "" {
+			violations = append(violations, licenseViolation{
+				Name:    pkg.Name,
+				Version: pkg.Version,
+				License: "-",
+				Reason:  "no license information",
+			})
+			continue
+		}
+
+		if p.permitsExpression(expression) {
+			continue
+		}
+
+		var reasons []string
+		for _, license := range licenseIdentifiers(expression) {
+			if pattern, denied := matchLicense(p.Deny, license); denied {
+				reasons = append(reasons, fmt.Sprintf("%s is denied (%s)", license, pattern))
+			} else if _, allowed := matchLicense(p.Allow, license); len(p.Allow) > 0 && !allowed {
+				reasons = append(reasons, fmt.Sprintf("%s is not allowed", license))
+			}
+		}
+
+		violations = append(violations, licenseViolation{
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			License: expression,
+			Reason:  strings.Join(reasons, ", "),
+		})
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Name != violations[j].Name {
+			return violations[i].Name < violations[j].Name
+		}
+		return violations[i].Version < violations[j].Version
+	})
+
+	return violations
+}
+
+// digest identifies the policy in the metadata of the launch layer, so that a
+// reused layer is checked again when the policy changes.
+func (p LicensePolicy) digest() string {
+	allow := append([]string{}, p.Allow...)
+	deny := append([]string{}, p.Deny...)
+	sort.Strings(allow)
+	sort.Strings(deny)
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("allow=%s\ndeny=%s", strings.Join(allow, ","), strings.Join(deny, ","))))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p LicensePolicy) permits(license string) bool {
+	if _, denied := matchLicense(p.Deny, license); denied {
+		return false
+	}
+
+	if len(p.Allow) == 0 {
+		return true
+	}
+
+	_, allowed := matchLicense(p.Allow, license)
+	return allowed
+}
+
+// permitsExpression evaluates an SPDX license expression such as
+// "(MIT OR Apache-2.0) AND BSD-3-Clause". Strings that are not valid
+// expressions, e.g. "SEE LICENSE IN LICENSE.txt", are treated as a single
+// license identifier.
+func (p LicensePolicy) permitsExpression(expression string) bool {
+	parser := &licenseExpressionParser{tokens: licenseTokens(expression)}
+	permitted, err := parser.parseOr(p.permits)
+	if err != nil || parser.position != len(parser.tokens) {
+		return p.permits(expression)
+	}
+
+	return permitted
+}
+
+func matchLicense(patterns []string, license string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(license)); matched {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+func licenseTokens(expression string) []string {
+	expression = strings.ReplaceAll(expression, "(", " ( ")
+	expression = strings.ReplaceAll(expression, ")", " ) ")
+	return strings.Fields(expression)
+}
+
+// licenseIdentifiers returns the license identifiers referenced by an SPDX
+// license expression, or the expression itself when it is not valid.
+func licenseIdentifiers(expression string) []string {
+	var identifiers []string
+	parser := &licenseExpressionParser{tokens: licenseTokens(expression)}
+	_, err := parser.parseOr(func(license string) bool {
+		identifiers = append(identifiers, license)
+		return true
+	})
+	if err != nil || parser.position != len(parser.tokens) {
+		return []string{expression}
+	}
+
+	return identifiers
+}
+
+type licenseExpressionParser struct {
+	tokens   []string
+	position int
+}
+
+var errMalformedLicenseExpression = errors.New("malformed license expression")
+
+func (p *licenseExpressionParser) peek() string {
+	if p.position < len(p.tokens) {
+		return strings.ToUpper(p.tokens[p.position])
+	}
+	return ""
+}
+
+func (p *licenseExpressionParser) parseOr(permits func(string) bool) (bool, error) {
+	permitted, err := p.parseAnd(permits)
+	if err != nil {
+		return false, err
+	}
+
+	for p.peek() == "OR" {
+		p.position++
+		right, err := p.parseAnd(permits)
+		if err != nil {
+			return false, err
+		}
+		permitted = permitted || right
+	}
+
+	return permitted, nil
+}
+
+func (p *licenseExpressionParser) parseAnd(permits func(string) bool) (bool, error) {
+	permitted, err := p.parseAtom(permits)
+	if err != nil {
+		return false, err
+	}
+
+	for p.peek() == "AND" {
+		p.position++
+		right, err := p.parseAtom(permits)
+		if err != nil {
+			return false, err
+		}
+		permitted = permitted && right
+	}
+
+	return permitted, nil
+}
+
+func (p *licenseExpressionParser) parseAtom(permits func(string) bool) (bool, error) {
+	switch p.peek() {
+	case "", ")", "AND", "OR", "WITH":
+		return false, errMalformedLicenseExpression
+
+	case "(":
+		p.position++
+		permitted, err := p.parseOr(permits)
+		if err != nil {
+			return false, err
+		}
+
+		if p.peek() != ")" {
+			return false, errMalformedLicenseExpression
+		}
+		p.position++
+
+		return permitted, nil
+	}
+
+	license := p.tokens[p.position]
+	p.position++
+
+	if p.peek() == "WITH" {
+		p.position += 2
+		if p.position > len(p.tokens) {
+			return false, errMalformedLicenseExpression
+		}
+	}
+
+	return permits(license), nil
+}
@@ -0,0 +1,202 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anchore/syft/syft/file"
+	"github.com/anchore/syft/syft/pkg"
+	syftsbom "github.com/anchore/syft/syft/sbom"
+	"github.com/anchore/syft/syft/source"
+	"github.com/paketo-buildpacks/libnodejs"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+)
+
+// LockfileSBOMGenerator generates an SBOM from the yarn.lock and the
+// package.json files of the installed packages instead of scanning the whole
+// directory tree with syft.
+type LockfileSBOMGenerator struct {
+	workingDir string
+}
+
+func NewLockfileSBOMGenerator(workingDir string) LockfileSBOMGenerator {
+	return LockfileSBOMGenerator{
+		workingDir: workingDir,
+	}
+}
+
+type installedPackage struct {
+	Name     string            `json:"name"`
+	Version  string            `json:"version"`
+	License  interface{}       `json:"license"`
+	Licenses interface{}       `json:"licenses"`
+	Scripts  map[string]string `json:"scripts"`
+	Gypfile  bool              `json:"gypfile"`
+
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+
+	path string
+}
+
+// Generate returns an SBOM describing the packages installed in the
+// node_modules directory of the given layer path.
+func (g LockfileSBOMGenerator) Generate(dir string) (sbom.SBOM, error) {
+	projectPath, err := libnodejs.FindProjectPath(g.workingDir)
+	if err != nil {
+		return sbom.SBOM{}, err
+	}
+
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return sbom.SBOM{}, err
+	}
+
+	lockfile := map[string]LockfileEntry{}
+	for _, entry := range entries {
+		lockfile[fmt.Sprintf("%s@%s", entry.Name, entry.Version)] = entry
+	}
+
+	installed, err := findInstalledPackages(filepath.Join(dir, "node_modules"))
+	if err != nil {
+		return sbom.SBOM{}, err
+	}
+
+	collection := pkg.NewCollection()
+	for _, p := range installed {
+		licenses := pkg.NewLicenseSet()
+		for _, license := range p.licenses() {
+			licenses.Add(pkg.NewLicense(license))
+		}
+
+		syftPackage := pkg.Package{
+			Name:      p.Name,
+			Version:   p.Version,
+			Type:      pkg.NpmPkg,
+			Language:  pkg.JavaScript,
+			PURL:      npmPURL(p.Name, p.Version),
+			Licenses:  licenses,
+			Locations: file.NewLocationSet(file.NewLocation(p.path)),
+		}
+
+		if entry, ok := lockfile[fmt.Sprintf("%s@%s", p.Name, p.Version)]; ok {
+			syftPackage.Metadata = pkg.YarnLockEntry{
+				Resolved:     entry.Resolved,
+				Integrity:    entry.Integrity,
+				Dependencies: entry.Dependencies,
+			}
+		}
+
+		syftPackage.SetID()
+		collection.Add(syftPackage)
+	}
+
+	return sbom.NewSBOM(syftsbom.SBOM{
+		Artifacts: syftsbom.Artifacts{
+			Packages: collection,
+		},
+		Source: source.Description{
+			Metadata: source.DirectoryMetadata{
+				Path: dir,
+			},
+		},
+	}), nil
+}
+
+// findInstalledPackages walks a node_modules directory, including scoped and
+// nested node_modules directories, and returns the parsed package.json of
+// every installed package.
+func findInstalledPackages(modulesDir string) ([]installedPackage, error) {
+	dirEntries, err := os.ReadDir(modulesDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", modulesDir, err)
+	}
+
+	var packages []installedPackage
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if strings.HasPrefix(name, ".") || !dirEntry.IsDir() {
+			continue
+		}
+
+		if strings.HasPrefix(name, "@") {
+			scoped, err := findInstalledPackages(filepath.Join(modulesDir, name))
+			if err != nil {
+				return nil, err
+			}
+			packages = append(packages, scoped...)
+			continue
+		}
+
+		packageDir := filepath.Join(modulesDir, name)
+		content, err := os.ReadFile(filepath.Join(packageDir, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read package.json: %w", err)
+		}
+
+		var p installedPackage
+		err = json.Unmarshal(content, &p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(packageDir, "package.json"), err)
+		}
+		p.path = filepath.Join(packageDir, "package.json")
+
+		if p.Name != "" {
+			packages = append(packages, p)
+		}
+
+		nested, err := findInstalledPackages(filepath.Join(packageDir, "node_modules"))
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, nested...)
+	}
+
+	return packages, nil
+}
+
+// licenses returns the license identifiers declared in the package.json,
+// accepting both the current "license" field and the deprecated "licenses"
+// field in their string, object and array forms.
+func (p installedPackage) licenses() []string {
+	var licenses []string
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				licenses = append(licenses, v)
+			}
+		case map[string]interface{}:
+			collect(v["type"])
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+
+	collect(p.License)
+	collect(p.Licenses)
+
+	return licenses
+}
+
+func npmPURL(name, version string) string {
+	if scope, packageName, found := strings.Cut(name, "/"); found && strings.HasPrefix(scope, "@") {
+		return fmt.Sprintf("pkg:npm/%%40%s/%s@%s", url.PathEscape(strings.TrimPrefix(scope, "@")), url.PathEscape(packageName), url.PathEscape(version))
+	}
+
+	return fmt.Sprintf("pkg:npm/%s@%s", url.PathEscape(name), url.PathEscape(version))
+}
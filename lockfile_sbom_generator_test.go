@@ -0,0 +1,123 @@
+package yarninstall_test
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLockfileSBOMGenerator(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		layerDir   string
+		generator  yarninstall.LockfileSBOMGenerator
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+		layerDir = t.TempDir()
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`# yarn lockfile v1
+
+"@some-scope/left-pad@^1.3.0":
+  version "1.3.0"
+  resolved "https://registry.yarnpkg.com/@some-scope/left-pad/-/left-pad-1.3.0.tgz#5b8a3a7"
+  integrity sha512-left-pad-integrity==
+
+right-pad@^1.0.0:
+  version "1.0.1"
+  resolved "https://registry.yarnpkg.com/right-pad/-/right-pad-1.0.1.tgz#0a1b2c3"
+  integrity sha512-right-pad-integrity==
+`), os.ModePerm)).To(Succeed())
+
+		modulesDir := filepath.Join(layerDir, "node_modules")
+		Expect(os.MkdirAll(filepath.Join(modulesDir, "@some-scope", "left-pad"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(modulesDir, "@some-scope", "left-pad", "package.json"), []byte(`{
+			"name": "@some-scope/left-pad",
+			"version": "1.3.0",
+			"license": "MIT"
+		}`), os.ModePerm)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(modulesDir, "@some-scope", "left-pad", "node_modules", "right-pad"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(modulesDir, "@some-scope", "left-pad", "node_modules", "right-pad", "package.json"), []byte(`{
+			"name": "right-pad",
+			"version": "1.0.1",
+			"licenses": [{ "type": "Apache-2.0" }]
+		}`), os.ModePerm)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(modulesDir, ".bin"), os.ModePerm)).To(Succeed())
+
+		generator = yarninstall.NewLockfileSBOMGenerator(workingDir)
+	})
+
+	context("Generate", func() {
+		it("returns an SBOM of the installed packages enriched with the yarn.lock", func() {
+			bom, err := generator.Generate(layerDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			formatter, err := bom.InFormats("application/vnd.syft+json", "application/vnd.cyclonedx+json", "application/spdx+json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formatter.Formats()).To(HaveLen(3))
+
+			content, err := io.ReadAll(formatter.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+
+			var syft struct {
+				Artifacts []struct {
+					Name     string `json:"name"`
+					Version  string `json:"version"`
+					PURL     string `json:"purl"`
+					Licenses []struct {
+						Value string `json:"value"`
+					} `json:"licenses"`
+					Metadata struct {
+						Resolved  string `json:"resolved"`
+						Integrity string `json:"integrity"`
+					} `json:"metadata"`
+				} `json:"artifacts"`
+			}
+			Expect(json.Unmarshal(content, &syft)).To(Succeed())
+			Expect(syft.Artifacts).To(HaveLen(2))
+
+			artifacts := map[string]int{}
+			for i, artifact := range syft.Artifacts {
+				artifacts[artifact.Name] = i
+			}
+
+			leftPad := syft.Artifacts[artifacts["@some-scope/left-pad"]]
+			Expect(leftPad.Version).To(Equal("1.3.0"))
+			Expect(leftPad.PURL).To(Equal("pkg:npm/%40some-scope/left-pad@1.3.0"))
+			Expect(leftPad.Licenses[0].Value).To(Equal("MIT"))
+			Expect(leftPad.Metadata.Resolved).To(Equal("https://registry.yarnpkg.com/@some-scope/left-pad/-/left-pad-1.3.0.tgz#5b8a3a7"))
+			Expect(leftPad.Metadata.Integrity).To(Equal("sha512-left-pad-integrity=="))
+
+			rightPad := syft.Artifacts[artifacts["right-pad"]]
+			Expect(rightPad.PURL).To(Equal("pkg:npm/right-pad@1.0.1"))
+			Expect(rightPad.Licenses[0].Value).To(Equal("Apache-2.0"))
+			Expect(rightPad.Metadata.Integrity).To(Equal("sha512-right-pad-integrity=="))
+		})
+
+		context("failure cases", func() {
+			context("when an installed package.json is malformed", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(layerDir, "node_modules", "broken"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(layerDir, "node_modules", "broken", "package.json"), []byte("%%%"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := generator.Generate(layerDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,408 @@
+package yarninstall
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// NativeAddon describes a compiled Node.js addon (.node file) found in a
+// modules layer.
+type NativeAddon struct {
+	Path              string   `toml:"path"`
+	Format            string   `toml:"format"`
+	Arch              string   `toml:"arch,omitempty"`
+	NodeModuleVersion string   `toml:"node_module_version,omitempty"`
+	Needed            []string `toml:"needed,omitempty"`
+	Missing           []string `toml:"missing,omitempty"`
+}
+
+// tinyStackLibraries are the shared libraries available on minimal run images
+// such as the tiny stacks, which only ship glibc and OpenSSL.
+var tinyStackLibraries = map[string]bool{
+	"libc.so.6":             true,
+	"libm.so.6":             true,
+	"libpthread.so.0":       true,
+	"libdl.so.2":            true,
+	"librt.so.1":            true,
+	"libresolv.so.2":        true,
+	"ld-linux-x86-64.so.2":  true,
+	"ld-linux-aarch64.so.1": true,
+	"libssl.so.3":           true,
+	"libcrypto.so.3":        true,
+	"linux-vdso.so.1":       true,
+}
+
+// defaultLibraryPaths returns the directories the dynamic linker searches for
+// shared libraries in the build container.
+func defaultLibraryPaths() []string {
+	var paths []string
+	for _, path := range filepath.SplitList(os.Getenv("LD_LIBRARY_PATH")) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	paths = append(paths, "/lib", "/lib64", "/usr/lib", "/usr/lib64", "/usr/local/lib")
+	for _, triplet := range []string{"x86_64-linux-gnu", "aarch64-linux-gnu"} {
+		paths = append(paths, filepath.Join("/lib", triplet), filepath.Join("/usr/lib", triplet))
+	}
+
+	return paths
+}
+
+// isTinyStack reports whether the stack ID names one of the minimal stacks,
+// whose run images only ship tinyStackLibraries. The stack ID is optional, so
+// other run images cannot be told apart and only the build environment is
+// checked for them.
+func isTinyStack(stack string) bool {
+	return strings.Contains(stack, "tiny") || strings.Contains(stack, "static")
+}
+
+// scanNativeAddons finds every .node file in the node_modules directory of
+// the given layer and records its architecture, the NODE_MODULE_VERSION it
+// was built for and the shared libraries it needs. A needed library is
+// reported as missing when it is neither bundled next to the addon (through
+// its RPATH or RUNPATH) nor available in the given library paths of the build
+// environment, or, on a tiny stack, when it is not part of the libraries that
+// stack ships.
+func scanNativeAddons(layerPath string, libraryPaths []string, stack string) ([]NativeAddon, error) {
+	modulesDir := filepath.Join(layerPath, "node_modules")
+	tiny := isTinyStack(stack)
+
+	var addons []NativeAddon
+	err := filepath.WalkDir(modulesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".node") {
+			return nil
+		}
+
+		addon, err := inspectNativeAddon(path)
+		if err != nil {
+			return err
+		}
+
+		for _, library := range addon.Needed {
+			if !libraryAvailable(path, library, addon.searchPaths, libraryPaths, tiny) {
+				addon.Missing = append(addon.Missing, library)
+			}
+		}
+
+		addon.Path, err = filepath.Rel(modulesDir, path)
+		if err != nil {
+			return err
+		}
+
+		addons = append(addons, addon.NativeAddon)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for native addons: %w", err)
+	}
+
+	sort.Slice(addons, func(i, j int) bool {
+		return addons[i].Path < addons[j].Path
+	})
+
+	return addons, nil
+}
+
+// foreignPackage is a package with native addons that cannot be loaded on
+// the platform the modules are installed for.
+type foreignPackage struct {
+	Path    string
+	Reasons []string
+}
+
+// findForeignAddons finds the packages in modulesDir whose native addons are
+// not Linux ELF binaries for the given architecture, for instance because the
+// node_modules directory was vendored from macOS or another architecture.
+func findForeignAddons(modulesDir, arch string) ([]foreignPackage, error) {
+	var packages []foreignPackage
+	err := filepath.WalkDir(modulesDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".node") {
+			return nil
+		}
+
+		addon, err := inspectNativeAddon(path)
+		if err != nil {
+			return err
+		}
+
+		var reason string
+		switch {
+		case addon.Format != "elf" && addon.Arch != "":
+			reason = fmt.Sprintf("is a %s binary for %s", addon.Format, addon.Arch)
+		case addon.Format != "elf":
+			reason = fmt.Sprintf("is a %s binary", addon.Format)
+		case addon.Arch != arch:
+			reason = fmt.Sprintf("is built for %s, not %s", addon.Arch, arch)
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(modulesDir, path)
+		if err != nil {
+			return err
+		}
+
+		packageDir := packageDirectory(rel)
+		if packageDir == "" {
+			return nil
+		}
+
+		addonPath, err := filepath.Rel(packageDir, rel)
+		if err != nil {
+			return err
+		}
+		reason = fmt.Sprintf("%s %s", addonPath, reason)
+
+		if len(packages) > 0 && packages[len(packages)-1].Path == packageDir {
+			packages[len(packages)-1].Reasons = append(packages[len(packages)-1].Reasons, reason)
+			return nil
+		}
+
+		packages = append(packages, foreignPackage{Path: packageDir, Reasons: []string{reason}})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for native addons: %w", err)
+	}
+
+	return packages, nil
+}
+
+// packageDirectory returns the directory of the package that contains the
+// file at the given path relative to a node_modules directory, taking nested
+// node_modules directories and scoped packages into account.
+func packageDirectory(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+
+	start := 0
+	for i, part := range parts[:len(parts)-1] {
+		if part == "node_modules" {
+			start = i + 1
+		}
+	}
+
+	end := start + 1
+	if strings.HasPrefix(parts[start], "@") {
+		end++
+	}
+
+	if end >= len(parts) {
+		return ""
+	}
+
+	return filepath.Join(parts[:end]...)
+}
+
+type inspectedAddon struct {
+	NativeAddon
+	searchPaths []string
+}
+
+func inspectNativeAddon(path string) (inspectedAddon, error) {
+	header := make([]byte, 4)
+	file, err := os.Open(path)
+	if err != nil {
+		return inspectedAddon{}, err
+	}
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil {
+		return inspectedAddon{NativeAddon: NativeAddon{Format: "unknown"}}, nil
+	}
+
+	addon := inspectedAddon{NativeAddon: NativeAddon{Format: binaryFormat(header)}}
+	if addon.Format == "mach-o" {
+		// Universal binaries and files that cannot be parsed are left without
+		// an architecture.
+		if f, err := macho.Open(path); err == nil {
+			addon.Arch = machoArch(f.Cpu)
+			f.Close()
+		}
+	}
+
+	if addon.Format != "elf" {
+		return addon, nil
+	}
+
+	f, err := elf.Open(path)
+	if err != nil {
+		return inspectedAddon{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	defer f.Close()
+
+	addon.Arch = elfArch(f.Machine)
+
+	addon.Needed, err = f.ImportedLibraries()
+	if err != nil {
+		return inspectedAddon{}, fmt.Errorf("failed to read needed libraries of %s: %w", path, err)
+	}
+
+	for _, tag := range []elf.DynTag{elf.DT_RUNPATH, elf.DT_RPATH} {
+		values, err := f.DynString(tag)
+		if err != nil {
+			return inspectedAddon{}, fmt.Errorf("failed to read library search paths of %s: %w", path, err)
+		}
+
+		for _, value := range values {
+			for _, searchPath := range filepath.SplitList(value) {
+				searchPath = strings.ReplaceAll(searchPath, "${ORIGIN}", filepath.Dir(path))
+				searchPath = strings.ReplaceAll(searchPath, "$ORIGIN", filepath.Dir(path))
+				addon.searchPaths = append(addon.searchPaths, searchPath)
+			}
+		}
+	}
+
+	symbols, err := f.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return inspectedAddon{}, fmt.Errorf("failed to read symbols of %s: %w", path, err)
+	}
+
+	for _, symbol := range symbols {
+		if version, ok := strings.CutPrefix(symbol.Name, "node_register_module_v"); ok {
+			addon.NodeModuleVersion = version
+			break
+		}
+
+		if strings.HasPrefix(symbol.Name, "napi_register_module_v") {
+			addon.NodeModuleVersion = "napi"
+		}
+	}
+
+	return addon, nil
+}
+
+func libraryAvailable(addonPath, library string, addonSearchPaths, libraryPaths []string, tiny bool) bool {
+	if filepath.IsAbs(library) {
+		_, err := os.Stat(library)
+		return err == nil
+	}
+
+	// Libraries bundled with the addon are shipped in the same layer.
+	for _, dir := range append([]string{filepath.Dir(addonPath)}, addonSearchPaths...) {
+		if _, err := os.Stat(filepath.Join(dir, library)); err == nil {
+			return true
+		}
+	}
+
+	if tiny {
+		return tinyStackLibraries[library]
+	}
+
+	for _, dir := range libraryPaths {
+		if _, err := os.Stat(filepath.Join(dir, library)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func binaryFormat(header []byte) string {
+	switch {
+	case bytes.Equal(header, []byte("\x7fELF")):
+		return "elf"
+	case bytes.Equal(header, []byte{0xcf, 0xfa, 0xed, 0xfe}),
+		bytes.Equal(header, []byte{0xce, 0xfa, 0xed, 0xfe}),
+		bytes.Equal(header, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		return "mach-o"
+	case bytes.HasPrefix(header, []byte("MZ")):
+		return "pe"
+	}
+
+	return "unknown"
+}
+
+func elfArch(machine elf.Machine) string {
+	switch machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_PPC64:
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_RISCV:
+		return "riscv64"
+	}
+
+	return strings.ToLower(strings.TrimPrefix(machine.String(), "EM_"))
+}
+
+func machoArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuArm:
+		return "arm"
+	}
+
+	return strings.ToLower(strings.TrimPrefix(cpu.String(), "Cpu"))
+}
+
+// logNativeAddons prints the native addons found in a layer and a warning for
+// every library they need that is missing from the given environment.
+func logNativeAddons(addons []NativeAddon, environment string, logger scribe.Emitter) {
+	logger.Process("Inspecting native addons")
+	logger.Subprocess("Found %d native addons:", len(addons))
+	for _, addon := range addons {
+		var details []string
+		if addon.Arch != "" {
+			details = append(details, addon.Arch)
+		} else {
+			details = append(details, addon.Format)
+		}
+
+		switch addon.NodeModuleVersion {
+		case "":
+		case "napi":
+			details = append(details, "Node-API")
+		default:
+			details = append(details, fmt.Sprintf("NODE_MODULE_VERSION %s", addon.NodeModuleVersion))
+		}
+
+		logger.Action("%s (%s)", addon.Path, strings.Join(details, ", "))
+	}
+
+	for _, addon := range addons {
+		for _, library := range addon.Missing {
+			logger.Subprocess("Warning: %s needs %s, which is not available in the %s", addon.Path, library, environment)
+		}
+	}
+	logger.Break()
+}
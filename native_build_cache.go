@@ -0,0 +1,122 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// nativeBuildCaches maps the environment variables that configure where
+// native builds keep their downloads to a directory in the cache layer:
+// node-gyp stores Node.js headers in its devdir, while prebuild-install (used
+// by sharp and many others) and the npm install that esbuild falls back to
+// keep their downloads in the npm cache.
+var nativeBuildCaches = []struct {
+	env string
+	dir string
+}{
+	{env: "npm_config_devdir", dir: "node-gyp"},
+	{env: "npm_config_cache", dir: "npm"},
+}
+
+// nativeBuildEnvironment returns the environment for yarn install and npm
+// rebuild that points native builds at the cache layer and, when a binding of
+// type node-headers is provided, at local Node.js headers so that node-gyp
+// does not download them. Variables that are already set in the environment
+// are left as-is.
+func nativeBuildEnvironment(bindingResolver BindingResolver, logger scribe.Emitter, cacheLayerPath, platformDir string) ([]string, error) {
+	var env []string
+	if cacheLayerPath != "" {
+		for _, cache := range nativeBuildCaches {
+			if _, ok := os.LookupEnv(cache.env); ok {
+				continue
+			}
+
+			dir := filepath.Join(cacheLayerPath, cache.dir)
+			err := os.MkdirAll(dir, os.ModePerm)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create native build cache: %w", err)
+			}
+
+			env = append(env, fmt.Sprintf("%s=%s", cache.env, dir))
+		}
+	}
+
+	binding, err := resolveOptionalBinding(bindingResolver, "node-headers", platformDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if binding == nil {
+		return env, nil
+	}
+
+	logger.Process("Loading service binding of type 'node-headers'")
+
+	info, err := os.Stat(filepath.Join(binding.Path, "include", "node"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read node-headers binding: %w", err)
+	}
+
+	if info != nil && info.IsDir() {
+		logger.Subprocess("Using Node.js headers from %s", binding.Path)
+		logger.Break()
+		return append(env, fmt.Sprintf("npm_config_nodedir=%s", binding.Path)), nil
+	}
+
+	var tarballs []string
+	for name := range binding.Entries {
+		if strings.HasSuffix(name, ".tar.gz") {
+			tarballs = append(tarballs, name)
+		}
+	}
+	sort.Strings(tarballs)
+
+	if len(tarballs) != 1 {
+		return nil, fmt.Errorf("failed: binding of type 'node-headers' must contain either an include/node directory or exactly one headers tarball (*.tar.gz)")
+	}
+
+	tarball := filepath.Join(binding.Path, tarballs[0])
+	logger.Subprocess("Using Node.js headers from %s", tarball)
+	logger.Break()
+
+	return append(env, fmt.Sprintf("npm_config_tarball=%s", tarball)), nil
+}
+
+// hasNativeBuildDownloads reports whether any of the native build or binary
+// download caches in the cache layer contains files.
+func hasNativeBuildDownloads(cacheLayerPath string) (bool, error) {
+	var dirs []string
+	for _, cache := range nativeBuildCaches {
+		dirs = append(dirs, cache.dir)
+	}
+	for _, download := range binaryDownloads {
+		dirs = append(dirs, download.cacheDir)
+	}
+
+	for _, dir := range dirs {
+		ok, err := hasContents(filepath.Join(cacheLayerPath, dir))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+func hasContents(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read download cache: %w", err)
+	}
+
+	return len(entries) > 0, nil
+}
@@ -0,0 +1,254 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// nodeGypDependencies are the packages a package depends on when it compiles
+// a native addon from source.
+var nodeGypDependencies = []string{"nan", "node-addon-api", "node-gyp"}
+
+// prebuiltLoaders are the packages that install a prebuilt native addon and
+// only fall back to compiling it when no prebuilt binary is available.
+var prebuiltLoaders = []string{"@mapbox/node-pre-gyp", "node-pre-gyp", "prebuild-install", "node-gyp-build"}
+
+// findNodeGypPackages returns the packages in the yarn.lock and the vendored
+// node_modules directory of the project that compile native addons with
+// node-gyp during yarn install: lockfile entries that depend on a native
+// addon toolkit without a prebuilt binary loader, and vendored packages with
+// a binding.gyp file or the gypfile flag. Packages that are only reachable
+// through optionalDependencies are returned separately, since yarn only
+// warns when they fail to compile.
+func findNodeGypPackages(projectPath string) (required []string, optional []string, err error) {
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	optionalOnly, err := optionalOnlyPackages(projectPath, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := map[string]bool{}
+	add := func(name, version string) {
+		key := fmt.Sprintf("%s@%s", name, version)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		if optionalOnly[key] {
+			optional = append(optional, key)
+		} else {
+			required = append(required, key)
+		}
+	}
+
+	for _, entry := range entries {
+		if compilesFromSource(entry.Dependencies, entry.OptionalDependencies) {
+			add(entry.Name, entry.Version)
+		}
+	}
+
+	packages, err := findInstalledPackages(filepath.Join(projectPath, "node_modules"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, p := range packages {
+		if hasDependency(prebuiltLoaders, p.Dependencies, p.OptionalDependencies) {
+			continue
+		}
+
+		_, err := os.Stat(filepath.Join(filepath.Dir(p.path), "binding.gyp"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("failed to stat binding.gyp: %w", err)
+		}
+
+		if err == nil || p.Gypfile || hasDependency(nodeGypDependencies, p.Dependencies, p.OptionalDependencies) {
+			add(p.Name, p.Version)
+		}
+	}
+
+	sort.Strings(required)
+	sort.Strings(optional)
+
+	return required, optional, nil
+}
+
+// optionalOnlyPackages returns the "name@version" keys of the yarn.lock
+// entries that the project's package.json reaches only through
+// optionalDependencies. Entries that are not reachable at all are not
+// included, so that they are treated as required.
+func optionalOnlyPackages(projectPath string, entries []LockfileEntry) (map[string]bool, error) {
+	content, err := os.ReadFile(filepath.Join(projectPath, "package.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	err = json.Unmarshal(content, &pkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	bySpecifier := map[string]LockfileEntry{}
+	for _, entry := range entries {
+		for _, specifier := range entry.Specifiers {
+			bySpecifier[specifier] = entry
+		}
+	}
+
+	var visit func(reached map[string]bool, name, versionRange string, followOptional bool)
+	visit = func(reached map[string]bool, name, versionRange string, followOptional bool) {
+		entry, ok := bySpecifier[fmt.Sprintf("%s@%s", name, versionRange)]
+		if !ok {
+			return
+		}
+
+		key := fmt.Sprintf("%s@%s", entry.Name, entry.Version)
+		if reached[key] {
+			return
+		}
+		reached[key] = true
+
+		for dependency, versionRange := range entry.Dependencies {
+			visit(reached, dependency, versionRange, followOptional)
+		}
+
+		if followOptional {
+			for dependency, versionRange := range entry.OptionalDependencies {
+				visit(reached, dependency, versionRange, followOptional)
+			}
+		}
+	}
+
+	required := map[string]bool{}
+	for _, dependencies := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for name, versionRange := range dependencies {
+			visit(required, name, versionRange, false)
+		}
+	}
+
+	reachable := map[string]bool{}
+	for _, dependencies := range []map[string]string{pkg.Dependencies, pkg.DevDependencies, pkg.OptionalDependencies} {
+		for name, versionRange := range dependencies {
+			visit(reachable, name, versionRange, true)
+		}
+	}
+
+	optionalOnly := map[string]bool{}
+	for key := range reachable {
+		if !required[key] {
+			optionalOnly[key] = true
+		}
+	}
+
+	return optionalOnly, nil
+}
+
+func compilesFromSource(dependencies ...map[string]string) bool {
+	return hasDependency(nodeGypDependencies, dependencies...) && !hasDependency(prebuiltLoaders, dependencies...)
+}
+
+func hasDependency(names []string, dependencies ...map[string]string) bool {
+	for _, deps := range dependencies {
+		for _, name := range names {
+			if _, ok := deps[name]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// missingBuildTools returns the tools node-gyp needs that are not available
+// on the PATH of the build environment.
+func missingBuildTools() []string {
+	var missing []string
+	for _, tool := range []struct {
+		name        string
+		executables []string
+	}{
+		{name: "python", executables: []string{"python3", "python"}},
+		{name: "make", executables: []string{"make"}},
+		{name: "a C++ compiler", executables: []string{"c++", "g++", "clang++"}},
+	} {
+		found := false
+		for _, executable := range tool.executables {
+			if _, err := exec.LookPath(executable); err == nil {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, tool.name)
+		}
+	}
+
+	return missing
+}
+
+// checkBuildTools fails the build before anything is installed when packages
+// need to be compiled with node-gyp but the tools it needs are missing.
+// Optional packages only produce a warning, since yarn carries on without them
+// when they fail to compile.
+func checkBuildTools(projectPath string, logger scribe.Emitter) error {
+	skip, err := parseBoolEnv("BP_YARN_SKIP_BUILD_TOOLS_CHECK")
+	if err != nil {
+		return err
+	}
+
+	if skip {
+		return nil
+	}
+
+	required, optional, err := findNodeGypPackages(projectPath)
+	if err != nil {
+		return err
+	}
+
+	if len(required) == 0 && len(optional) == 0 {
+		return nil
+	}
+
+	missing := missingBuildTools()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(required) == 0 {
+		logger.Subprocess("Warning: the following optional packages compile native addons with node-gyp, which needs %s; yarn installs without them if they fail to compile:", strings.Join(missing, ", "))
+		for _, p := range optional {
+			logger.Action(p)
+		}
+		logger.Break()
+
+		return nil
+	}
+
+	return fmt.Errorf("failed: the following packages compile native addons with node-gyp, which needs python, make and a C++ compiler:\n%s\n"+
+		"Missing from the build environment: %s. Add a buildpack that provides python (this buildpack optionally requires %q in the build plan) "+
+		"or use a builder whose build image includes these tools, such as the Paketo Full builder. "+
+		"Set BP_YARN_SKIP_BUILD_TOOLS_CHECK=true to skip this check.",
+		formatPackageList(required), strings.Join(missing, ", "), PlanDependencyCPython)
+}
@@ -0,0 +1,120 @@
+package yarninstall
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ProxyConfiguration holds the HTTP(S) proxy settings that are handed to
+// every yarn invocation made by the install process.
+type ProxyConfiguration struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// resolveProxyConfiguration reads the proxy settings from a binding of type
+// 'proxy', falling back to the standard proxy environment variables for any
+// entry that the binding does not provide.
+func resolveProxyConfiguration(bindingResolver BindingResolver, platformDir string) (ProxyConfiguration, error) {
+	proxy := ProxyConfiguration{
+		HTTPProxy:  lookupProxyEnv("HTTP_PROXY"),
+		HTTPSProxy: lookupProxyEnv("HTTPS_PROXY"),
+		NoProxy:    lookupProxyEnv("NO_PROXY"),
+	}
+
+	binding, err := resolveOptionalBinding(bindingResolver, "proxy", platformDir)
+	if err != nil {
+		return ProxyConfiguration{}, err
+	}
+
+	if binding != nil {
+		for entry, field := range map[string]*string{
+			"http-proxy":  &proxy.HTTPProxy,
+			"https-proxy": &proxy.HTTPSProxy,
+			"no-proxy":    &proxy.NoProxy,
+		} {
+			if e, ok := binding.Entries[entry]; ok {
+				value, err := e.ReadString()
+				if err != nil {
+					return ProxyConfiguration{}, fmt.Errorf("failed to read '%s' entry of proxy binding: %w", entry, err)
+				}
+				*field = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return proxy, nil
+}
+
+func lookupProxyEnv(name string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return os.Getenv(strings.ToLower(name))
+}
+
+// Args returns the yarn command line flags that configure the proxy.
+func (p ProxyConfiguration) Args() []string {
+	var args []string
+	if p.HTTPProxy != "" {
+		args = append(args, "--proxy", p.HTTPProxy)
+	}
+
+	if p.HTTPSProxy != "" {
+		args = append(args, "--https-proxy", p.HTTPSProxy)
+	}
+
+	return args
+}
+
+// Environment returns the environment variables that configure the proxy for
+// yarn itself as well as for any tooling that is invoked by lifecycle scripts
+// (node-pre-gyp, prebuild-install, etc).
+func (p ProxyConfiguration) Environment() []string {
+	var env []string
+	if p.HTTPProxy != "" {
+		env = append(env,
+			fmt.Sprintf("HTTP_PROXY=%s", p.HTTPProxy),
+			fmt.Sprintf("http_proxy=%s", p.HTTPProxy),
+			fmt.Sprintf("npm_config_proxy=%s", p.HTTPProxy),
+		)
+	}
+
+	if p.HTTPSProxy != "" {
+		env = append(env,
+			fmt.Sprintf("HTTPS_PROXY=%s", p.HTTPSProxy),
+			fmt.Sprintf("https_proxy=%s", p.HTTPSProxy),
+			fmt.Sprintf("npm_config_https_proxy=%s", p.HTTPSProxy),
+		)
+	}
+
+	if p.NoProxy != "" {
+		env = append(env,
+			fmt.Sprintf("NO_PROXY=%s", p.NoProxy),
+			fmt.Sprintf("no_proxy=%s", p.NoProxy),
+			fmt.Sprintf("npm_config_noproxy=%s", p.NoProxy),
+		)
+	}
+
+	return env
+}
+
+// redactProxyArgs returns a copy of the given arguments in which any proxy URL
+// credentials have been masked so that they can be safely logged.
+func redactProxyArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = arg
+		if i > 0 && (args[i-1] == "--proxy" || args[i-1] == "--https-proxy") {
+			if u, err := url.Parse(arg); err == nil && u.User != nil {
+				redacted[i] = u.Redacted()
+			}
+		}
+	}
+
+	return redacted
+}
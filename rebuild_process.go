@@ -0,0 +1,211 @@
+package yarninstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// NpmRebuildProcess recompiles the native addons of an installed
+// node_modules directory with npm, which, unlike yarn v1, can rebuild
+// individual packages without reinstalling them.
+type NpmRebuildProcess struct {
+	executable      Executable
+	bindingResolver BindingResolver
+	logger          scribe.Emitter
+}
+
+func NewNpmRebuildProcess(executable Executable, bindingResolver BindingResolver, logger scribe.Emitter) NpmRebuildProcess {
+	return NpmRebuildProcess{
+		executable:      executable,
+		bindingResolver: bindingResolver,
+		logger:          logger,
+	}
+}
+
+// NodeABI returns the NODE_MODULE_VERSION of the Node.js runtime in the
+// build environment.
+func (rp NpmRebuildProcess) NodeABI(workingDir string) (string, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	err := rp.executable.Execute(pexec.Execution{
+		Args:   []string{"version", "--json"},
+		Stdout: stdout,
+		Stderr: stderr,
+		Dir:    workingDir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute npm version:\n%s\nerror: %s", stderr.String(), err)
+	}
+
+	var versions map[string]string
+	err = json.Unmarshal(stdout.Bytes(), &versions)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse npm version output: %w", err)
+	}
+
+	abi, ok := versions["modules"]
+	if !ok {
+		return "", fmt.Errorf("failed to parse npm version output: missing modules version")
+	}
+
+	return abi, nil
+}
+
+// Rebuild runs npm rebuild for the given packages in the node_modules
+// directory of the modules layer and returns the command it ran. The rebuild
+// uses the same proxy, native build caches, Node.js headers and redaction as
+// yarn install.
+func (rp NpmRebuildProcess) Rebuild(workingDir, modulesLayerPath, cacheLayerPath, platformDir string, packages []string) ([]string, error) {
+	proxy, err := resolveProxyConfiguration(rp.bindingResolver, platformDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve proxy configuration: %w", err)
+	}
+
+	environment := os.Environ()
+	environment = append(environment, fmt.Sprintf("PATH=%s%c%s", os.Getenv("PATH"), os.PathListSeparator, filepath.Join(modulesLayerPath, "node_modules", ".bin")))
+	environment = append(environment, proxy.Environment()...)
+
+	nativeEnvironment, err := nativeBuildEnvironment(rp.bindingResolver, rp.logger, cacheLayerPath, platformDir)
+	if err != nil {
+		return nil, err
+	}
+	environment = append(environment, nativeEnvironment...)
+
+	redactor, err := newRedactor(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"rebuild"}, packages...)
+	command := fmt.Sprintf("npm %s", strings.Join(args, " "))
+	rp.logger.Subprocess("Running '%s'", command)
+
+	output := redactor.Writer(rp.logger.ActionWriter)
+	err = rp.executable.Execute(pexec.Execution{
+		Args:   args,
+		Env:    environment,
+		Stdout: output,
+		Stderr: output,
+		Dir:    modulesLayerPath,
+	})
+	if flushErr := output.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute npm rebuild: %s", redactor.Redact(err.Error()))
+	}
+
+	return []string{command}, nil
+}
+
+// nativeAddonPackages returns the names of the packages that contain the
+// native addons recorded in the metadata of a modules layer.
+func nativeAddonPackages(metadata map[string]interface{}) []string {
+	var paths []string
+	switch addons := metadata["native_addons"].(type) {
+	case []NativeAddon:
+		for _, addon := range addons {
+			paths = append(paths, addon.Path)
+		}
+	case []map[string]interface{}:
+		for _, addon := range addons {
+			if path, ok := addon["path"].(string); ok {
+				paths = append(paths, path)
+			}
+		}
+	case []interface{}:
+		for _, addon := range addons {
+			if addon, ok := addon.(map[string]interface{}); ok {
+				if path, ok := addon["path"].(string); ok {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var packages []string
+	for _, path := range paths {
+		packageDir := filepath.ToSlash(packageDirectory(path))
+		if packageDir == "" {
+			continue
+		}
+
+		if i := strings.LastIndex(packageDir, "node_modules/"); i >= 0 {
+			packageDir = packageDir[i+len("node_modules/"):]
+		}
+
+		if !seen[packageDir] {
+			seen[packageDir] = true
+			packages = append(packages, packageDir)
+		}
+	}
+	sort.Strings(packages)
+
+	return packages
+}
+
+// staleNativeAddons returns the current Node ABI and the packages with native
+// addons in a reused modules layer when they were built for another ABI. Layers
+// without native addons or without a recorded ABI are never stale.
+func staleNativeAddons(rebuildProcess RebuildProcess, workingDir string, metadata map[string]interface{}) (string, []string, error) {
+	previousABI, ok := metadata["node_abi"].(string)
+	if !ok {
+		return "", nil, nil
+	}
+
+	packages := nativeAddonPackages(metadata)
+	if len(packages) == 0 {
+		return "", nil, nil
+	}
+
+	abi, err := rebuildProcess.NodeABI(workingDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if abi == previousABI {
+		return abi, nil, nil
+	}
+
+	return abi, packages, nil
+}
+
+// allowedRebuildPackages splits the packages to rebuild into the ones that
+// npm rebuild may run the install scripts of and the ones it must skip. All
+// packages are allowed unless BP_YARN_IGNORE_SCRIPTS is set, in which case
+// only those listed in BP_YARN_ALLOWED_SCRIPTS are.
+func allowedRebuildPackages(packages []string) (allowed []string, skipped []string, err error) {
+	ignoreScripts, allowedScripts, err := parseIgnoreScripts()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ignoreScripts {
+		return packages, nil, nil
+	}
+
+	isAllowed := map[string]bool{}
+	for _, name := range allowedScripts {
+		isAllowed[name] = true
+	}
+
+	for _, p := range packages {
+		if isAllowed[p] {
+			allowed = append(allowed, p)
+		} else {
+			skipped = append(skipped, p)
+		}
+	}
+
+	return allowed, skipped, nil
+}
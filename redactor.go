@@ -0,0 +1,164 @@
+package yarninstall
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "***"
+
+var (
+	basicAuthURLPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+):[^/\s@]+@`)
+	authKeyPattern      = regexp.MustCompile(`(?i)((?:_authToken|_auth|_password|npmAuthToken)["']?\s*[=:]?\s*["']?)[^\s"']+`)
+	authHeaderPattern   = regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic|token)\s+)\S+`)
+	tokenPatterns       = []*regexp.Regexp{
+		regexp.MustCompile(`npm_[A-Za-z0-9]{36}`),
+		regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),
+		regexp.MustCompile(`github_pat_[A-Za-z0-9_]{22,}`),
+		regexp.MustCompile(`glpat-[A-Za-z0-9_-]{20,}`),
+	}
+)
+
+// Redactor masks secrets in yarn output and error messages before they reach
+// the build logs. Secrets are collected from the .npmrc and .yarnrc files that
+// configure yarn, in addition to basic-auth URLs and well-known token formats.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a Redactor that masks the credential values found in the
+// given .npmrc/.yarnrc files. Files that do not exist are ignored.
+func NewRedactor(configPaths ...string) (Redactor, error) {
+	var secrets []string
+	for _, path := range configPaths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return Redactor{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		secrets = append(secrets, parseConfigSecrets(content)...)
+	}
+
+	// Mask longer secrets first so that a secret that contains another secret
+	// is not left partially visible.
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+
+	return Redactor{secrets: secrets}, nil
+}
+
+func parseConfigSecrets(content []byte) []string {
+	var secrets []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		var key, value string
+		if index := strings.IndexAny(line, "= \t"); index >= 0 {
+			key, value = line[:index], line[index+1:]
+		}
+
+		key = strings.ToLower(strings.Trim(key, `"'`))
+		value = strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "=")), `"'`)
+
+		// Very short values are not masked since they would mangle unrelated
+		// output without protecting anything meaningful.
+		if !isSecretKey(key) || len(value) < 4 {
+			continue
+		}
+
+		secrets = append(secrets, value)
+		if expanded := os.ExpandEnv(value); expanded != value && expanded != "" {
+			secrets = append(secrets, expanded)
+		}
+	}
+
+	return secrets
+}
+
+func isSecretKey(key string) bool {
+	for _, suffix := range []string{"_authtoken", "_auth", "_password", "npmauthtoken", "password", "token"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact returns the given text with all known secrets masked.
+func (r Redactor) Redact(text string) string {
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+
+	text = basicAuthURLPattern.ReplaceAllString(text, "${1}:"+redactedValue+"@")
+	text = authKeyPattern.ReplaceAllString(text, "${1}"+redactedValue)
+	text = authHeaderPattern.ReplaceAllString(text, "${1}"+redactedValue)
+	for _, pattern := range tokenPatterns {
+		text = pattern.ReplaceAllString(text, redactedValue)
+	}
+
+	return text
+}
+
+// Writer wraps the given writer so that everything written through it is
+// redacted one line at a time. Flush must be called once writing is complete
+// to emit any trailing partial line.
+func (r Redactor) Writer(w io.Writer) *RedactingWriter {
+	return &RedactingWriter{
+		redactor: r,
+		writer:   w,
+	}
+}
+
+type RedactingWriter struct {
+	redactor Redactor
+	writer   io.Writer
+	buffer   bytes.Buffer
+}
+
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+
+	for {
+		index := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if index < 0 {
+			break
+		}
+
+		line := string(w.buffer.Next(index + 1))
+		_, err := io.WriteString(w.writer, w.redactor.Redact(line))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (w *RedactingWriter) Flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(w.writer, w.redactor.Redact(w.buffer.String()))
+	w.buffer.Reset()
+
+	return err
+}
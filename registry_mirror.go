@@ -0,0 +1,120 @@
+package yarninstall
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RegistryMirror maps a public registry host to the URL of the mirror that
+// should be used in its place.
+type RegistryMirror struct {
+	Host   string
+	Mirror string
+}
+
+// parseRegistryMirrors reads the BP_YARN_REGISTRY_MIRROR environment variable,
+// which holds a comma-separated list of host=mirror-url pairs, e.g.
+// registry.yarnpkg.com=https://artifactory.example.com/api/npm/npm-remote.
+// The mirrors are returned sorted by host so that they can be used as part of
+// a cache key.
+func parseRegistryMirrors() ([]RegistryMirror, error) {
+	value := strings.TrimSpace(os.Getenv("BP_YARN_REGISTRY_MIRROR"))
+	if value == "" {
+		return nil, nil
+	}
+
+	var mirrors []RegistryMirror
+	for _, pair := range strings.Split(value, ",") {
+		host, mirror, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || host == "" {
+			return nil, fmt.Errorf("failed to parse BP_YARN_REGISTRY_MIRROR: %q is not of the form host=mirror-url", pair)
+		}
+
+		u, err := url.Parse(mirror)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("failed to parse BP_YARN_REGISTRY_MIRROR: mirror for %q is not a valid URL", host)
+		}
+
+		mirrors = append(mirrors, RegistryMirror{
+			Host:   host,
+			Mirror: strings.TrimSuffix(mirror, "/"),
+		})
+	}
+
+	sort.Slice(mirrors, func(i, j int) bool {
+		return mirrors[i].Host < mirrors[j].Host
+	})
+
+	return mirrors, nil
+}
+
+// rewriteLockfileRegistries replaces the scheme and host of every resolved URL
+// in the given yarn.lock that matches a mirrored registry host. All other
+// lockfile content, including integrity hashes, is left untouched. The
+// returned function restores the original lockfile.
+func rewriteLockfileRegistries(path string, mirrors []RegistryMirror) (func() error, error) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read yarn.lock: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat yarn.lock: %w", err)
+	}
+
+	lines := strings.Split(string(original), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "resolved ") {
+			continue
+		}
+
+		resolved := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "resolved ")), `"`)
+		if rewritten := mirroredURL(resolved, mirrors); rewritten != resolved {
+			lines[i] = strings.Replace(line, resolved, rewritten, 1)
+		}
+	}
+
+	err = os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write rewritten yarn.lock: %w", err)
+	}
+
+	return func() error {
+		err := os.WriteFile(path, original, info.Mode())
+		if err != nil {
+			return fmt.Errorf("failed to restore yarn.lock: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// mirroredURL returns the resolved URL with its scheme and host replaced by
+// the mirror of that host, or unchanged when the host is not mirrored.
+func mirroredURL(resolved string, mirrors []RegistryMirror) string {
+	u, err := url.Parse(resolved)
+	if err != nil {
+		return resolved
+	}
+
+	for _, mirror := range mirrors {
+		if u.Host == mirror.Host {
+			return mirror.Mirror + strings.TrimPrefix(resolved, fmt.Sprintf("%s://%s", u.Scheme, u.Host))
+		}
+	}
+
+	return resolved
+}
+
+func (m RegistryMirror) String() string {
+	mirror := m.Mirror
+	if u, err := url.Parse(mirror); err == nil {
+		mirror = u.Redacted()
+	}
+
+	return fmt.Sprintf("%s -> %s", m.Host, mirror)
+}
@@ -0,0 +1,112 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+type registryPattern struct {
+	host           string
+	allowsInsecure bool
+}
+
+// checkAllowedRegistries enforces the BP_YARN_ALLOWED_REGISTRIES policy by
+// verifying that every resolved URL in the yarn.lock points at an allowed
+// host. The URLs are checked after the BP_YARN_REGISTRY_MIRROR mapping, as
+// they are downloaded during the install. Plain-text resolutions (http://,
+// git://) are only permitted for hosts whose pattern explicitly includes the
+// http:// scheme.
+func checkAllowedRegistries(projectPath string, logger scribe.Emitter) error {
+	value, ok := os.LookupEnv("BP_YARN_ALLOWED_REGISTRIES")
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	var patterns []registryPattern
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		pattern := registryPattern{host: field}
+		if scheme, host, found := strings.Cut(field, "://"); found {
+			pattern.host = host
+			pattern.allowsInsecure = scheme == "http"
+		}
+		pattern.host = strings.TrimSuffix(pattern.host, "/")
+
+		if _, err := path.Match(pattern.host, ""); err != nil {
+			return fmt.Errorf("failed to parse BP_YARN_ALLOWED_REGISTRIES pattern %q: %w", field, err)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	mirrors, err := parseRegistryMirrors()
+	if err != nil {
+		return err
+	}
+
+	logger.Process("Checking resolved URLs against BP_YARN_ALLOWED_REGISTRIES")
+
+	var violations []string
+	for _, entry := range entries {
+		if entry.Resolved == "" {
+			continue
+		}
+
+		// Resolutions without a host, such as file: tarballs or scp-style git
+		// URLs, cannot be matched against the patterns and are rejected.
+		u, err := url.Parse(mirroredURL(entry.Resolved, mirrors))
+		if err != nil || u.Host == "" {
+			violations = append(violations, fmt.Sprintf("  - %s@%s from unparseable resolution %q", entry.Name, entry.Version, Redactor{}.Redact(entry.Resolved)))
+			continue
+		}
+
+		insecure := u.Scheme == "http" || u.Scheme == "git" || u.Scheme == "git+http"
+
+		if !registryAllowed(patterns, u, insecure) {
+			reason := u.Host
+			if insecure {
+				reason = fmt.Sprintf("%s (insecure %s:// resolution)", u.Host, u.Scheme)
+			}
+			violations = append(violations, fmt.Sprintf("  - %s@%s from %s", entry.Name, entry.Version, reason))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("failed: the following packages are resolved from registries that are not allowed by BP_YARN_ALLOWED_REGISTRIES:\n%s", strings.Join(violations, "\n"))
+	}
+
+	logger.Subprocess("All resolved URLs are allowed")
+	logger.Break()
+
+	return nil
+}
+
+func registryAllowed(patterns []registryPattern, u *url.URL, insecure bool) bool {
+	for _, pattern := range patterns {
+		if insecure && !pattern.allowsInsecure {
+			continue
+		}
+
+		for _, host := range []string{u.Host, u.Hostname()} {
+			if matched, _ := path.Match(pattern.host, host); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
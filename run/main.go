@@ -24,10 +24,12 @@ func (s SBOMGenerator) Generate(path string) (sbom.SBOM, error) {
 
 func main() {
 	logger := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
-	installProcess := yarninstall.NewYarnInstallProcess(pexec.NewExecutable("yarn"), fs.NewChecksumCalculator(), logger)
+	bindingResolver := servicebindings.NewResolver()
+	rebuildProcess := yarninstall.NewNpmRebuildProcess(pexec.NewExecutable("npm"), bindingResolver, logger)
+	installProcess := yarninstall.NewYarnInstallProcess(pexec.NewExecutable("yarn"), rebuildProcess, fs.NewChecksumCalculator(), bindingResolver, logger)
 	sbomGenerator := SBOMGenerator{}
 	symlinker := yarninstall.NewSymlinker()
-	packageManagerConfigurationManager := yarninstall.NewPackageManagerConfigurationManager(servicebindings.NewResolver(), logger)
+	packageManagerConfigurationManager := yarninstall.NewPackageManagerConfigurationManager(bindingResolver, logger)
 	entryResolver := draft.NewPlanner()
 	home, err := os.UserHomeDir()
 	tmpDir := os.TempDir()
@@ -43,6 +45,7 @@ func main() {
 			home,
 			symlinker,
 			installProcess,
+			rebuildProcess,
 			sbomGenerator,
 			chronos.DefaultClock,
 			logger,
@@ -0,0 +1,157 @@
+package yarninstall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// SBOMCache persists formatted SBOMs across builds keyed by the cache_sha of
+// the layer they describe and the generator that produced them, so that
+// layers with identical inputs do not need to be rescanned.
+//
+// Entries are kept per layer name since the build and launch layers share a
+// cache_sha but hold different sets of packages.
+type SBOMCache struct {
+	path string
+}
+
+func NewSBOMCache(path string) SBOMCache {
+	return SBOMCache{
+		path: path,
+	}
+}
+
+type sbomCacheMetadata struct {
+	SHA       string            `toml:"sha"`
+	Generator string            `toml:"generator"`
+	Formats   map[string]string `toml:"formats"`
+}
+
+// Lookup returns the cached SBOM for the given layer when it was stored for
+// the same sha by the same generator and includes every requested media type.
+func (c SBOMCache) Lookup(layerName, sha, generator string, mediaTypes []string) (packit.SBOMFormatter, bool, error) {
+	if sha == "" {
+		return nil, false, nil
+	}
+
+	dir := filepath.Join(c.path, layerName)
+
+	var metadata sbomCacheMetadata
+	_, err := toml.DecodeFile(filepath.Join(dir, "metadata.toml"), &metadata)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read SBOM cache metadata: %w", err)
+	}
+
+	if metadata.SHA != sha || metadata.Generator != generator {
+		return nil, false, nil
+	}
+
+	var formatter cachedSBOMFormatter
+	for _, mediaType := range mediaTypes {
+		extension, ok := metadata.Formats[mediaType]
+		if !ok {
+			return nil, false, nil
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("sbom.%s", extension)))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read cached SBOM: %w", err)
+		}
+
+		formatter = append(formatter, cachedSBOMFormat{
+			extension: extension,
+			content:   content,
+		})
+	}
+
+	return formatter, true, nil
+}
+
+// Store renders the given formatter into the cache and returns a formatter
+// over the rendered content. The formats of the formatter are expected to be
+// in the same order as the given media types.
+func (c SBOMCache) Store(layerName, sha, generator string, mediaTypes []string, formatter packit.SBOMFormatter) (packit.SBOMFormatter, error) {
+	dir := filepath.Join(c.path, layerName)
+
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear SBOM cache: %w", err)
+	}
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SBOM cache: %w", err)
+	}
+
+	metadata := sbomCacheMetadata{
+		SHA:       sha,
+		Generator: generator,
+		Formats:   map[string]string{},
+	}
+
+	var cached cachedSBOMFormatter
+	for i, format := range formatter.Formats() {
+		content, err := io.ReadAll(format.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format SBOM: %w", err)
+		}
+
+		err = os.WriteFile(filepath.Join(dir, fmt.Sprintf("sbom.%s", format.Extension)), content, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write SBOM cache: %w", err)
+		}
+
+		if i < len(mediaTypes) {
+			metadata.Formats[mediaTypes[i]] = format.Extension
+		}
+
+		cached = append(cached, cachedSBOMFormat{
+			extension: format.Extension,
+			content:   content,
+		})
+	}
+
+	file, err := os.Create(filepath.Join(dir, "metadata.toml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write SBOM cache metadata: %w", err)
+	}
+	defer file.Close()
+
+	err = toml.NewEncoder(file).Encode(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write SBOM cache metadata: %w", err)
+	}
+
+	return cached, nil
+}
+
+type cachedSBOMFormat struct {
+	extension string
+	content   []byte
+}
+
+// cachedSBOMFormatter serves already rendered SBOM content, handing out a
+// fresh reader every time its formats are requested.
+type cachedSBOMFormatter []cachedSBOMFormat
+
+func (f cachedSBOMFormatter) Formats() []packit.SBOMFormat {
+	var formats []packit.SBOMFormat
+	for _, format := range f {
+		formats = append(formats, packit.SBOMFormat{
+			Extension: format.extension,
+			Content:   bytes.NewReader(format.content),
+		})
+	}
+
+	return formats
+}
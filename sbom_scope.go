@@ -0,0 +1,349 @@
+package yarninstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/paketo-buildpacks/libnodejs"
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+// dependencyScopes maps the "name@version" key of every package in the
+// yarn.lock to whether it is a production dependency, that is reachable from
+// the dependencies (as opposed to the devDependencies) of the project's
+// package.json. A nil map is returned when the project has no yarn.lock.
+func dependencyScopes(projectPath string) (map[string]bool, error) {
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	pkg, err := libnodejs.ParsePackageJSON(projectPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	bySpecifier := map[string]LockfileEntry{}
+	for _, entry := range entries {
+		for _, specifier := range entry.Specifiers {
+			bySpecifier[specifier] = entry
+		}
+	}
+
+	production := map[string]bool{}
+	for _, entry := range entries {
+		production[fmt.Sprintf("%s@%s", entry.Name, entry.Version)] = false
+	}
+
+	var visit func(name, versionRange string)
+	visit = func(name, versionRange string) {
+		entry, ok := bySpecifier[fmt.Sprintf("%s@%s", name, versionRange)]
+		if !ok {
+			return
+		}
+
+		key := fmt.Sprintf("%s@%s", entry.Name, entry.Version)
+		if production[key] {
+			return
+		}
+		production[key] = true
+
+		for dependency, versionRange := range entry.Dependencies {
+			visit(dependency, versionRange)
+		}
+
+		for dependency, versionRange := range entry.OptionalDependencies {
+			visit(dependency, versionRange)
+		}
+	}
+
+	for name, versionRange := range pkg.Dependencies {
+		visit(name, versionRange)
+	}
+
+	return production, nil
+}
+
+// scopedSBOMFormatter wraps an SBOMFormatter and marks every component of the
+// CycloneDX output that is part of the yarn.lock with a scope: "required" for
+// production dependencies and "excluded" for development dependencies.
+type scopedSBOMFormatter struct {
+	formatter  packit.SBOMFormatter
+	production map[string]bool
+}
+
+func newScopedSBOMFormatter(formatter packit.SBOMFormatter, production map[string]bool) packit.SBOMFormatter {
+	if production == nil {
+		return formatter
+	}
+
+	return scopedSBOMFormatter{
+		formatter:  formatter,
+		production: production,
+	}
+}
+
+func (f scopedSBOMFormatter) Formats() []packit.SBOMFormat {
+	var formats []packit.SBOMFormat
+	for _, format := range f.formatter.Formats() {
+		if format.Extension == "cdx.json" {
+			format.Content = &sbomRewriteReader{
+				reader:  format.Content,
+				rewrite: f.markScopes,
+			}
+		}
+
+		formats = append(formats, format)
+	}
+
+	return formats
+}
+
+func (f scopedSBOMFormatter) markScopes(bom map[string]interface{}) {
+	components, ok := bom["components"].([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, c := range components {
+		component, ok := c.(map[string]interface{})
+		if !ok || component["type"] != "library" {
+			continue
+		}
+
+		production, ok := f.production[fmt.Sprintf("%v@%v", component["name"], component["version"])]
+		if !ok {
+			continue
+		}
+
+		component["scope"] = "excluded"
+		if production {
+			component["scope"] = "required"
+		}
+	}
+}
+
+// installedSBOMFormatter wraps an SBOMFormatter of the whole project and
+// removes the npm packages that are not installed in a modules layer, along
+// with the relationships that refer to them, from every format.
+type installedSBOMFormatter struct {
+	formatter packit.SBOMFormatter
+	installed map[string]bool
+}
+
+func newInstalledSBOMFormatter(formatter packit.SBOMFormatter, layerPath string) (packit.SBOMFormatter, error) {
+	packages, err := findInstalledPackages(filepath.Join(layerPath, "node_modules"))
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	for _, p := range packages {
+		installed[fmt.Sprintf("%s@%s", p.Name, p.Version)] = true
+	}
+
+	return installedSBOMFormatter{
+		formatter: formatter,
+		installed: installed,
+	}, nil
+}
+
+func (f installedSBOMFormatter) Formats() []packit.SBOMFormat {
+	var formats []packit.SBOMFormat
+	for _, format := range f.formatter.Formats() {
+		var rewrite func(map[string]interface{})
+		switch format.Extension {
+		case "cdx.json":
+			rewrite = f.filterCycloneDX
+		case "spdx.json":
+			rewrite = f.filterSPDX
+		case "syft.json":
+			rewrite = f.filterSyft
+		}
+
+		if rewrite != nil {
+			format.Content = &sbomRewriteReader{
+				reader:  format.Content,
+				rewrite: rewrite,
+			}
+		}
+
+		formats = append(formats, format)
+	}
+
+	return formats
+}
+
+func (f installedSBOMFormatter) filterCycloneDX(bom map[string]interface{}) {
+	removed := f.removePackages(bom, "components", "bom-ref", "version")
+
+	dependencies, ok := bom["dependencies"].([]interface{})
+	if !ok {
+		return
+	}
+
+	kept := []interface{}{}
+	for _, d := range dependencies {
+		dependency, ok := d.(map[string]interface{})
+		if ok && removed[fmt.Sprint(dependency["ref"])] {
+			continue
+		}
+
+		if ok {
+			if dependsOn, ok := dependency["dependsOn"].([]interface{}); ok {
+				refs := []interface{}{}
+				for _, ref := range dependsOn {
+					if !removed[fmt.Sprint(ref)] {
+						refs = append(refs, ref)
+					}
+				}
+				dependency["dependsOn"] = refs
+			}
+		}
+
+		kept = append(kept, d)
+	}
+	bom["dependencies"] = kept
+}
+
+func (f installedSBOMFormatter) filterSPDX(document map[string]interface{}) {
+	removed := f.removePackages(document, "packages", "SPDXID", "versionInfo")
+	removeRelationships(document, "relationships", removed, "spdxElementId", "relatedSpdxElement")
+}
+
+func (f installedSBOMFormatter) filterSyft(document map[string]interface{}) {
+	removed := f.removePackages(document, "artifacts", "id", "version")
+	removeRelationships(document, "artifactRelationships", removed, "parent", "child")
+}
+
+// removePackages removes the npm packages that are not installed from the
+// given list of the document and returns the identifiers of the removed
+// packages.
+func (f installedSBOMFormatter) removePackages(document map[string]interface{}, key, idKey, versionKey string) map[string]bool {
+	removed := map[string]bool{}
+
+	packages, ok := document[key].([]interface{})
+	if !ok {
+		return removed
+	}
+
+	kept := []interface{}{}
+	for _, p := range packages {
+		if item, ok := p.(map[string]interface{}); ok && isNPMPackage(item) {
+			if !f.installed[fmt.Sprintf("%v@%v", item["name"], item[versionKey])] {
+				removed[fmt.Sprint(item[idKey])] = true
+				continue
+			}
+		}
+
+		kept = append(kept, p)
+	}
+	document[key] = kept
+
+	return removed
+}
+
+// isNPMPackage reports whether a package of any of the SBOM formats has an
+// npm package URL.
+func isNPMPackage(p map[string]interface{}) bool {
+	locators := []interface{}{p["purl"]}
+	if refs, ok := p["externalRefs"].([]interface{}); ok {
+		for _, r := range refs {
+			if ref, ok := r.(map[string]interface{}); ok {
+				locators = append(locators, ref["referenceLocator"])
+			}
+		}
+	}
+
+	for _, locator := range locators {
+		if locator, ok := locator.(string); ok && strings.HasPrefix(locator, "pkg:npm/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeRelationships removes the relationships that refer to any of the
+// removed identifiers through one of the given keys.
+func removeRelationships(document map[string]interface{}, key string, removed map[string]bool, idKeys ...string) {
+	relationships, ok := document[key].([]interface{})
+	if !ok {
+		return
+	}
+
+	kept := []interface{}{}
+	for _, r := range relationships {
+		if relationship, ok := r.(map[string]interface{}); ok && refersTo(relationship, removed, idKeys) {
+			continue
+		}
+
+		kept = append(kept, r)
+	}
+	document[key] = kept
+}
+
+func refersTo(relationship map[string]interface{}, ids map[string]bool, idKeys []string) bool {
+	for _, idKey := range idKeys {
+		if ids[fmt.Sprint(relationship[idKey])] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sbomRewriteReader decodes a JSON SBOM on the first read, rewrites it and
+// returns the re-encoded document.
+type sbomRewriteReader struct {
+	reader  io.Reader
+	rewrite func(map[string]interface{})
+
+	once   sync.Once
+	buffer *bytes.Reader
+	err    error
+}
+
+func (r *sbomRewriteReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		var document map[string]interface{}
+		r.err = json.NewDecoder(r.reader).Decode(&document)
+		if r.err != nil {
+			r.err = fmt.Errorf("failed to decode SBOM: %w", r.err)
+			return
+		}
+
+		r.rewrite(document)
+
+		content := bytes.NewBuffer(nil)
+		encoder := json.NewEncoder(content)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(document)
+		if err != nil {
+			r.err = fmt.Errorf("failed to encode SBOM: %w", err)
+			return
+		}
+
+		r.buffer = bytes.NewReader(content.Bytes())
+	})
+
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return r.buffer.Read(p)
+}
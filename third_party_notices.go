@@ -0,0 +1,104 @@
+package yarninstall
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const noticesSeparator = "================================================================================"
+
+// writeThirdPartyNotices writes a notices file to the given path that lists
+// every package installed in the given node_modules directory together with
+// its declared license and the content of its LICENSE and NOTICE files. It
+// returns the number of packages listed.
+func writeThirdPartyNotices(modulesDir, path string) (int, error) {
+	installed, err := findInstalledPackages(modulesDir)
+	if err != nil {
+		return 0, err
+	}
+
+	sort.SliceStable(installed, func(i, j int) bool {
+		if installed[i].Name != installed[j].Name {
+			return installed[i].Name < installed[j].Name
+		}
+		return installed[i].Version < installed[j].Version
+	})
+
+	buffer := bytes.NewBuffer(nil)
+	fmt.Fprintln(buffer, "THIRD-PARTY SOFTWARE NOTICES")
+	fmt.Fprintln(buffer)
+	fmt.Fprintln(buffer, "This file lists the npm packages shipped with this application along with")
+	fmt.Fprintln(buffer, "their licenses and notices.")
+
+	seen := map[string]bool{}
+	for _, p := range installed {
+		id := fmt.Sprintf("%s@%s", p.Name, p.Version)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		license := strings.Join(p.licenses(), " AND ")
+		if license == "" {
+			license = "UNKNOWN"
+		}
+
+		fmt.Fprintln(buffer)
+		fmt.Fprintln(buffer, noticesSeparator)
+		fmt.Fprintln(buffer, id)
+		fmt.Fprintf(buffer, "License: %s\n", license)
+
+		files, err := noticeFiles(filepath.Dir(p.path))
+		if err != nil {
+			return 0, err
+		}
+
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+
+			fmt.Fprintln(buffer)
+			fmt.Fprintf(buffer, "--- %s ---\n", filepath.Base(file))
+			fmt.Fprintln(buffer, strings.TrimRight(string(content), "\n"))
+		}
+	}
+
+	err = os.WriteFile(path, buffer.Bytes(), 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write third-party notices: %w", err)
+	}
+
+	return len(seen), nil
+}
+
+// noticeFiles returns the LICENSE, LICENCE, COPYING and NOTICE files found at
+// the top level of a package directory, e.g. LICENSE.md or NOTICE.txt.
+func noticeFiles(packageDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", packageDir, err)
+	}
+
+	var files []string
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+
+		name := strings.ToUpper(dirEntry.Name())
+		for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"} {
+			if strings.HasPrefix(name, prefix) {
+				files = append(files, filepath.Join(packageDir, dirEntry.Name()))
+				break
+			}
+		}
+	}
+
+	return files, nil
+}
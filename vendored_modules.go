@@ -0,0 +1,102 @@
+package yarninstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// yarnIntegrity is the subset of the .yarn-integrity file that yarn v1 writes
+// into node_modules after an install.
+type yarnIntegrity struct {
+	Flags           []string          `json:"flags"`
+	LockfileEntries map[string]string `json:"lockfileEntries"`
+}
+
+// installScripts are the lifecycle scripts yarn runs when a package is
+// installed.
+var installScripts = []string{"preinstall", "install", "postinstall"}
+
+// checkVendoredModules reports why the vendored node_modules directory at
+// modulesDir cannot be used without running yarn install. It returns an empty
+// reason when the .yarn-integrity file matches the yarn.lock in projectPath
+// and the install mode, and no package builds native code or runs install
+// scripts.
+func checkVendoredModules(projectPath, modulesDir string, production bool) (string, error) {
+	content, err := os.ReadFile(filepath.Join(modulesDir, ".yarn-integrity"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "node_modules/.yarn-integrity not found", nil
+		}
+		return "", fmt.Errorf("failed to read yarn integrity file: %w", err)
+	}
+
+	var integrity yarnIntegrity
+	err = json.Unmarshal(content, &integrity)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse yarn integrity file: %w", err)
+	}
+
+	entries, err := ParseYarnLock(filepath.Join(projectPath, "yarn.lock"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "yarn.lock not found", nil
+		}
+		return "", err
+	}
+
+	lockfileEntries := map[string]string{}
+	for _, entry := range entries {
+		for _, specifier := range entry.Specifiers {
+			lockfileEntries[specifier] = entry.Resolved
+		}
+	}
+
+	if len(lockfileEntries) != len(integrity.LockfileEntries) {
+		return "node_modules/.yarn-integrity does not match yarn.lock", nil
+	}
+
+	for specifier, resolved := range lockfileEntries {
+		if installed, ok := integrity.LockfileEntries[specifier]; !ok || installed != resolved {
+			return "node_modules/.yarn-integrity does not match yarn.lock", nil
+		}
+	}
+
+	if slices.Contains(integrity.Flags, "production") != production {
+		if production {
+			return "node_modules includes development dependencies", nil
+		}
+		return "node_modules does not include development dependencies", nil
+	}
+
+	packages, err := findInstalledPackages(modulesDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range packages {
+		packageDir := filepath.Dir(p.path)
+
+		if p.Gypfile {
+			return fmt.Sprintf("%s@%s builds a native addon", p.Name, p.Version), nil
+		}
+
+		_, err := os.Stat(filepath.Join(packageDir, "binding.gyp"))
+		if err == nil {
+			return fmt.Sprintf("%s@%s builds a native addon", p.Name, p.Version), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to stat binding.gyp: %w", err)
+		}
+
+		for _, script := range installScripts {
+			if p.Scripts[script] != "" {
+				return fmt.Sprintf("%s@%s has a %s script", p.Name, p.Version, script), nil
+			}
+		}
+	}
+
+	return "", nil
+}
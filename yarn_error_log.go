@@ -0,0 +1,109 @@
+package yarninstall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const yarnErrorLogName = "yarn-error.log"
+
+// yarnErrorLogSections are the sections of yarn-error.log that explain a
+// failure. The manifests and the lockfile are left out since they are part of
+// the app source.
+var yarnErrorLogSections = []string{"Arguments", "Yarn version", "Node version", "Platform", "Trace"}
+
+type yarnErrorLogSection struct {
+	Name  string
+	Lines []string
+}
+
+// statYarnErrorLog returns the file info of the yarn-error.log in the working
+// directory, or nil if there is none.
+func statYarnErrorLog(workingDir string) (os.FileInfo, error) {
+	info, err := os.Stat(filepath.Join(workingDir, yarnErrorLogName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to stat %s: %w", yarnErrorLogName, err)
+	}
+
+	return info, nil
+}
+
+// parseYarnErrorLog splits a yarn-error.log into its sections. Each section
+// starts with an unindented "Name:" line followed by lines indented by two
+// spaces.
+func parseYarnErrorLog(content string) []yarnErrorLogSection {
+	var sections []yarnErrorLogSection
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if line != "" && !strings.HasPrefix(line, " ") && strings.HasSuffix(strings.TrimSpace(line), ":") {
+			sections = append(sections, yarnErrorLogSection{
+				Name: strings.TrimSuffix(strings.TrimSpace(line), ":"),
+			})
+			continue
+		}
+
+		if len(sections) == 0 {
+			continue
+		}
+
+		current := &sections[len(sections)-1]
+		current.Lines = append(current.Lines, strings.TrimPrefix(line, "  "))
+	}
+
+	for i := range sections {
+		lines := sections[i].Lines
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		sections[i].Lines = lines
+	}
+
+	return sections
+}
+
+// logYarnErrorLog prints the relevant sections of the yarn-error.log that a
+// failed install left in the working directory. A log that is unchanged since
+// before the install is stale and is not printed.
+func (ip YarnInstallProcess) logYarnErrorLog(workingDir string, previous os.FileInfo, redactor Redactor) {
+	info, err := statYarnErrorLog(workingDir)
+	if err != nil || info == nil {
+		return
+	}
+
+	if previous != nil && info.ModTime().Equal(previous.ModTime()) && info.Size() == previous.Size() {
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join(workingDir, yarnErrorLogName))
+	if err != nil {
+		ip.logger.Subprocess("Failed to read %s: %s", yarnErrorLogName, err)
+		ip.logger.Break()
+		return
+	}
+
+	sections := map[string]yarnErrorLogSection{}
+	for _, section := range parseYarnErrorLog(string(content)) {
+		sections[section.Name] = section
+	}
+
+	ip.logger.Break()
+	ip.logger.Subprocess("Relevant sections of %s:", yarnErrorLogName)
+	for _, name := range yarnErrorLogSections {
+		section, ok := sections[name]
+		if !ok || len(section.Lines) == 0 {
+			continue
+		}
+
+		ip.logger.Action("%s:", section.Name)
+		for _, line := range section.Lines {
+			ip.logger.Detail("%s", redactor.Redact(line))
+		}
+	}
+	ip.logger.Break()
+}
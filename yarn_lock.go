@@ -0,0 +1,150 @@
+package yarninstall
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LockfileEntry is a single resolved package in a yarn v1 lockfile.
+type LockfileEntry struct {
+	Name                 string
+	Specifiers           []string
+	Version              string
+	Resolved             string
+	Integrity            string
+	Dependencies         map[string]string
+	OptionalDependencies map[string]string
+}
+
+// ParseYarnLock parses the yarn v1 lockfile at the given path. Entries are
+// returned in the order in which they appear in the lockfile.
+func ParseYarnLock(path string) ([]LockfileEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open yarn.lock: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		entries []LockfileEntry
+		entry   *LockfileEntry
+		section map[string]string
+	)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			if entry != nil {
+				entries = append(entries, *entry)
+			}
+
+			specifiers := splitSpecifiers(strings.TrimSuffix(trimmed, ":"))
+			entry = &LockfileEntry{
+				Name:                 packageNameFromSpecifier(specifiers[0]),
+				Specifiers:           specifiers,
+				Dependencies:         map[string]string{},
+				OptionalDependencies: map[string]string{},
+			}
+			section = nil
+
+		case entry == nil:
+			return nil, fmt.Errorf("failed to parse yarn.lock: unexpected line %q", line)
+
+		case indent == 2:
+			key, value := splitKeyValue(trimmed)
+			section = nil
+
+			switch key {
+			case "version":
+				entry.Version = value
+			case "resolved":
+				entry.Resolved = value
+			case "integrity":
+				entry.Integrity = value
+			case "dependencies:":
+				section = entry.Dependencies
+			case "optionalDependencies:":
+				section = entry.OptionalDependencies
+			}
+
+		default:
+			if section != nil {
+				key, value := splitKeyValue(trimmed)
+				section[key] = value
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read yarn.lock: %w", err)
+	}
+
+	if entry != nil {
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+func splitSpecifiers(line string) []string {
+	var specifiers []string
+	for _, specifier := range strings.Split(line, ",") {
+		specifiers = append(specifiers, strings.Trim(strings.TrimSpace(specifier), `"`))
+	}
+
+	return specifiers
+}
+
+func splitKeyValue(line string) (string, string) {
+	key, value, _ := strings.Cut(line, " ")
+	return strings.Trim(key, `"`), strings.Trim(strings.TrimSpace(value), `"`)
+}
+
+// packageNameFromSpecifier returns the package name portion of a lockfile
+// specifier such as "@babel/core@^7.0.0" or "left-pad@github:org/left-pad".
+func packageNameFromSpecifier(specifier string) string {
+	if specifier == "" {
+		return ""
+	}
+
+	index := strings.Index(specifier[1:], "@")
+	if index < 0 {
+		return specifier
+	}
+
+	return specifier[:index+1]
+}
+
+// specifierRange returns the version range portion of a lockfile specifier.
+func specifierRange(specifier string) string {
+	return strings.TrimPrefix(specifier, packageNameFromSpecifier(specifier)+"@")
+}
+
+// lockfileEntryNames returns a sorted, de-duplicated list of "name@version"
+// strings for the given entries, suitable for error messages.
+func lockfileEntryNames(entries []LockfileEntry) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, entry := range entries {
+		name := fmt.Sprintf("%s@%s", entry.Name, entry.Version)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
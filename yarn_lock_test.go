@@ -0,0 +1,96 @@
+package yarninstall_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	yarninstall "github.com/paketo-buildpacks/yarn-install"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testYarnLock(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		workingDir = t.TempDir()
+	})
+
+	context("ParseYarnLock", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte(`# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4":
+  version "7.10.4"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.10.4.tgz#168da1a36e90da68ae8d49c0f1b48c7c6249213a"
+  integrity sha512-vG6SvB6oYEhvgisZNFRmRCUkLz11c7rp+tbNTynGqc6mS1d5ATd/sGyV6W0KZZnXRKMTzZDRgQT3Ou9jhpAfUg==
+  dependencies:
+    "@babel/highlight" "^7.10.4"
+  optionalDependencies:
+    fsevents "~2.1.2"
+
+left-pad@github:some-org/left-pad:
+  version "1.3.0"
+  resolved "git+ssh://git@github.com/some-org/left-pad.git#5b8b2d9b8e4b1c1a0d2a8d9c0e1f2a3b4c5d6e7f"
+`), os.ModePerm)).To(Succeed())
+		})
+
+		it("returns the entries in the lockfile", func() {
+			entries, err := yarninstall.ParseYarnLock(filepath.Join(workingDir, "yarn.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(entries).To(Equal([]yarninstall.LockfileEntry{
+				{
+					Name:       "@babel/code-frame",
+					Specifiers: []string{"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.10.4"},
+					Version:    "7.10.4",
+					Resolved:   "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.10.4.tgz#168da1a36e90da68ae8d49c0f1b48c7c6249213a",
+					Integrity:  "sha512-vG6SvB6oYEhvgisZNFRmRCUkLz11c7rp+tbNTynGqc6mS1d5ATd/sGyV6W0KZZnXRKMTzZDRgQT3Ou9jhpAfUg==",
+					Dependencies: map[string]string{
+						"@babel/highlight": "^7.10.4",
+					},
+					OptionalDependencies: map[string]string{
+						"fsevents": "~2.1.2",
+					},
+				},
+				{
+					Name:                 "left-pad",
+					Specifiers:           []string{"left-pad@github:some-org/left-pad"},
+					Version:              "1.3.0",
+					Resolved:             "git+ssh://git@github.com/some-org/left-pad.git#5b8b2d9b8e4b1c1a0d2a8d9c0e1f2a3b4c5d6e7f",
+					Dependencies:         map[string]string{},
+					OptionalDependencies: map[string]string{},
+				},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the yarn.lock does not exist", func() {
+				it("returns an error", func() {
+					_, err := yarninstall.ParseYarnLock(filepath.Join(workingDir, "no-such-file"))
+					Expect(err).To(MatchError(ContainSubstring("failed to open yarn.lock")))
+					Expect(err).To(MatchError(os.ErrNotExist))
+				})
+			})
+
+			context("when the yarn.lock is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "yarn.lock"), []byte("  version \"1.0.0\"\n"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := yarninstall.ParseYarnLock(filepath.Join(workingDir, "yarn.lock"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse yarn.lock")))
+				})
+			})
+		})
+	})
+}